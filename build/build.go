@@ -0,0 +1,297 @@
+package build
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/params"
+)
+
+const (
+	idLength  = 16
+	retention = 24 * time.Hour
+)
+
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrTransition = errors.New("invalid transition")
+)
+
+type Build interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Add(context.Context, *dispatch.Request) (*Record, error)
+	Get(context.Context, string) (*Record, error)
+	List(context.Context, *Filter) ([]*Record, error)
+	Update(context.Context, *dispatch.Result) (*Record, error)
+}
+
+type Config struct {
+	Config config.Config
+	Logger hclog.Logger
+}
+
+// Filter to select records, empty fields match all
+type Filter struct {
+	Change   int
+	Connect  string
+	Group    string
+	Job      string
+	Patchset int
+}
+
+// Record to store lifecycle of one dispatched build
+type Record struct {
+	Change   int               `json:"change"`
+	Connect  string            `json:"connect"`
+	Created  time.Time         `json:"created"`
+	Dispatch string            `json:"dispatch"`
+	Duration time.Duration     `json:"duration"`
+	Group    string            `json:"group"`
+	Id       string            `json:"id"`
+	Job      string            `json:"job"`
+	Patchset int               `json:"patchset"`
+	Request  *dispatch.Request `json:"-"`
+	Started  time.Time         `json:"started"`
+	Status   string            `json:"status"`
+	Updated  time.Time         `json:"updated"`
+	Url      string            `json:"url"`
+	seq      uint64
+}
+
+type build struct {
+	cfg       *Config
+	mutex     sync.RWMutex
+	records   map[string]*Record
+	retention time.Duration
+	seq       uint64
+}
+
+func New(_ context.Context, cfg *Config) Build {
+	return &build{
+		cfg:       cfg,
+		records:   map[string]*Record{},
+		retention: time.Duration(cfg.Config.Spec.History.RetentionSeconds) * time.Second,
+	}
+}
+
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+func (b *build) Init(_ context.Context) error {
+	b.cfg.Logger.Debug("build: Init")
+
+	if b.retention <= 0 {
+		b.retention = retention
+	}
+
+	return nil
+}
+
+func (b *build) Deinit(_ context.Context) error {
+	b.cfg.Logger.Debug("build: Deinit")
+
+	return nil
+}
+
+func (b *build) Add(_ context.Context, req *dispatch.Request) (*Record, error) {
+	b.cfg.Logger.Debug("build: Add")
+
+	id, err := newId()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create id")
+	}
+
+	req.Id = id
+
+	if req.Params == nil {
+		req.Params = map[string]string{}
+	}
+
+	req.Params[params.ParamsTriggerBuildId] = id
+
+	now := time.Now()
+
+	r := &Record{
+		Connect:  req.Connect,
+		Created:  now,
+		Dispatch: req.Dispatch,
+		Group:    req.Group,
+		Id:       id,
+		Job:      req.Job,
+		Request:  req,
+		Status:   dispatch.StatusQueued,
+		Updated:  now,
+	}
+
+	if req.Event != nil {
+		r.Change = req.Event.Change.Number
+		r.Patchset = req.Event.PatchSet.Number
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.seq++
+	r.seq = b.seq
+
+	b.prune(now)
+	b.records[id] = r
+
+	return r.clone(), nil
+}
+
+func (b *build) Get(_ context.Context, id string) (*Record, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	r, ok := b.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return r.clone(), nil
+}
+
+func (b *build) List(_ context.Context, f *Filter) ([]*Record, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	buf := make([]*Record, 0, len(b.records))
+
+	for _, item := range b.records {
+		if f != nil && !f.match(item) {
+			continue
+		}
+		buf = append(buf, item.clone())
+	}
+
+	sort.Slice(buf, func(i, j int) bool {
+		return buf[i].seq < buf[j].seq
+	})
+
+	return buf, nil
+}
+
+func (b *build) Update(_ context.Context, result *dispatch.Result) (*Record, error) {
+	b.cfg.Logger.Debug("build: Update")
+
+	if result.Request == nil {
+		return nil, ErrNotFound
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	r, ok := b.records[result.Request.Id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if !transition(r.Status, result.Status) {
+		return nil, errors.Wrap(ErrTransition, r.Status+" to "+result.Status)
+	}
+
+	r.Status = result.Status
+	r.Updated = time.Now()
+
+	if result.Status == dispatch.StatusStarted && r.Started.IsZero() {
+		r.Started = r.Updated
+	}
+
+	if Done(result.Status) {
+		if r.Started.IsZero() {
+			r.Duration = r.Updated.Sub(r.Created)
+		} else {
+			r.Duration = r.Updated.Sub(r.Started)
+		}
+	}
+
+	if result.Url != "" {
+		r.Url = result.Url
+	}
+
+	return r.clone(), nil
+}
+
+// prune to expire records not updated in retention, including those never finished,
+// e.g., dispatched by log or webhook without callback
+func (b *build) prune(now time.Time) {
+	for id, item := range b.records {
+		if now.Sub(item.Updated) > b.retention {
+			delete(b.records, id)
+		}
+	}
+}
+
+// Done to check if status is terminal
+func Done(status string) bool {
+	switch status {
+	case dispatch.StatusAborted, dispatch.StatusFailed, dispatch.StatusSucceeded, dispatch.StatusUnstable:
+		return true
+	default:
+		return false
+	}
+}
+
+func transition(from, to string) bool {
+	if Done(from) {
+		return false
+	}
+
+	switch to {
+	case dispatch.StatusStarted:
+		return from == dispatch.StatusQueued || from == dispatch.StatusStarted
+	default:
+		return Done(to)
+	}
+}
+
+func (f *Filter) match(r *Record) bool {
+	if f.Change > 0 && f.Change != r.Change {
+		return false
+	}
+
+	if f.Connect != "" && f.Connect != r.Connect {
+		return false
+	}
+
+	if f.Group != "" && f.Group != r.Group {
+		return false
+	}
+
+	if f.Job != "" && f.Job != r.Job {
+		return false
+	}
+
+	if f.Patchset > 0 && f.Patchset != r.Patchset {
+		return false
+	}
+
+	return true
+}
+
+func (r *Record) clone() *Record {
+	buf := *r
+	return &buf
+}
+
+func newId() (string, error) {
+	buf := make([]byte, idLength)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,143 @@
+package build
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
+	"github.com/gerrittrigger/trigger/params"
+)
+
+func initBuild() *build {
+	b := &build{
+		cfg:     DefaultConfig(),
+		records: map[string]*Record{},
+	}
+
+	b.cfg.Config = config.Config{}
+
+	b.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "build",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	return b
+}
+
+func initRequest(change, patchset int) *dispatch.Request {
+	return &dispatch.Request{
+		Connect: "gerrit",
+		Event: &events.Event{
+			Change:   events.Change{Number: change},
+			PatchSet: events.PatchSet{Number: patchset},
+		},
+		Job: "build",
+	}
+}
+
+func TestAdd(t *testing.T) {
+	b := initBuild()
+	ctx := context.Background()
+
+	_ = b.Init(ctx)
+
+	req := initRequest(1, 2)
+
+	r, err := b.Add(ctx, req)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, idLength*2, len(r.Id))
+	assert.Equal(t, r.Id, req.Id)
+	assert.Equal(t, r.Id, req.Params[params.ParamsTriggerBuildId])
+	assert.Equal(t, 1, r.Change)
+	assert.Equal(t, 2, r.Patchset)
+	assert.Equal(t, dispatch.StatusQueued, r.Status)
+
+	_, err = b.Get(ctx, r.Id)
+	assert.Equal(t, nil, err)
+
+	_, err = b.Get(ctx, "invalid")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestList(t *testing.T) {
+	b := initBuild()
+	ctx := context.Background()
+
+	_ = b.Init(ctx)
+
+	_, _ = b.Add(ctx, initRequest(1, 1))
+	_, _ = b.Add(ctx, initRequest(1, 2))
+	_, _ = b.Add(ctx, initRequest(2, 1))
+
+	buf, err := b.List(ctx, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(buf))
+
+	buf, _ = b.List(ctx, &Filter{Change: 1})
+	assert.Equal(t, 2, len(buf))
+
+	buf, _ = b.List(ctx, &Filter{Change: 1, Patchset: 2})
+	assert.Equal(t, 1, len(buf))
+
+	buf, _ = b.List(ctx, &Filter{Job: "invalid"})
+	assert.Equal(t, 0, len(buf))
+}
+
+func TestUpdate(t *testing.T) {
+	b := initBuild()
+	ctx := context.Background()
+
+	_ = b.Init(ctx)
+
+	req := initRequest(1, 2)
+	_, _ = b.Add(ctx, req)
+
+	r, err := b.Update(ctx, &dispatch.Result{Request: req, Status: dispatch.StatusStarted, Url: "http://localhost/1/"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, dispatch.StatusStarted, r.Status)
+	assert.Equal(t, "http://localhost/1/", r.Url)
+
+	r, err = b.Update(ctx, &dispatch.Result{Request: req, Status: dispatch.StatusSucceeded})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, dispatch.StatusSucceeded, r.Status)
+	assert.Equal(t, "http://localhost/1/", r.Url)
+
+	_, err = b.Update(ctx, &dispatch.Result{Request: req, Status: dispatch.StatusFailed})
+	assert.ErrorIs(t, err, ErrTransition)
+
+	_, err = b.Update(ctx, &dispatch.Result{Request: &dispatch.Request{Id: "invalid"}, Status: dispatch.StatusFailed})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestPrune(t *testing.T) {
+	b := initBuild()
+	ctx := context.Background()
+
+	_ = b.Init(ctx)
+
+	req := initRequest(1, 2)
+	_, _ = b.Add(ctx, req)
+	_, _ = b.Update(ctx, &dispatch.Result{Request: req, Status: dispatch.StatusFailed})
+
+	b.records[req.Id].Updated = time.Now().Add(-2 * retention)
+
+	queued := initRequest(1, 3)
+	_, _ = b.Add(ctx, queued)
+
+	_, err := b.Get(ctx, req.Id)
+	assert.Equal(t, ErrNotFound, err)
+
+	// Record never finished is expired too
+	b.records[queued.Id].Updated = time.Now().Add(-2 * retention)
+
+	_, _ = b.Add(ctx, initRequest(1, 4))
+
+	_, err = b.Get(ctx, queued.Id)
+	assert.Equal(t, ErrNotFound, err)
+}
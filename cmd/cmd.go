@@ -1,41 +1,93 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/hashicorp/go-hclog"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v3"
 
+	"github.com/gerrittrigger/trigger/build"
 	"github.com/gerrittrigger/trigger/config"
 	"github.com/gerrittrigger/trigger/connect"
+	"github.com/gerrittrigger/trigger/deadletter"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
 	"github.com/gerrittrigger/trigger/filter"
 	"github.com/gerrittrigger/trigger/playback"
 	"github.com/gerrittrigger/trigger/query"
 	"github.com/gerrittrigger/trigger/queue"
 	"github.com/gerrittrigger/trigger/report"
+	"github.com/gerrittrigger/trigger/review"
+	"github.com/gerrittrigger/trigger/server"
+	"github.com/gerrittrigger/trigger/supersede"
 	"github.com/gerrittrigger/trigger/trigger"
+	"github.com/gerrittrigger/trigger/validate"
 	"github.com/gerrittrigger/trigger/watchdog"
+	"github.com/gerrittrigger/trigger/worker"
 )
 
 const (
-	level = "INFO"
-	name  = "trigger"
-	num   = -1
+	dryRunBuffer    = 10 * 1024 * 1024
+	level           = "INFO"
+	name            = "trigger"
+	num             = -1
+	queuePath       = "/api/v1/queue"
+	reinjectPath    = "/api/v1/events"
+	reinjectTimeout = 10 * time.Second
 )
 
 var (
 	app        = kingpin.New(name, "gerrit trigger").Version(config.Version + "-build-" + config.Build)
 	configFile = app.Flag("config-file", "Config file (.yml)").Required().String()
 	logLevel   = app.Flag("log-level", "Log level (DEBUG|INFO|WARN|ERROR)").Default(level).String()
+
+	runCommand      = app.Command("run", "Run trigger").Default()
+	validateCommand = app.Command("validate", "Validate config file")
+
+	dryRunCommand = app.Command("dry-run", "Evaluate recorded events without connection")
+	dryRunEvents  = dryRunCommand.Flag("events", "Events file (.jsonl)").Required().String()
+	dryRunExplain = dryRunCommand.Flag("explain", "Explain rules evaluated for each job").Bool()
+
+	reinjectCommand = app.Command("reinject", "Re-inject dead-lettered events into running trigger")
+	reinjectUrl     = reinjectCommand.Flag("server-url", "Server URL (default: spec.server.addr)").String()
+
+	queueCommand           = app.Command("queue", "Manage event queue of running trigger")
+	queueConnect           = queueCommand.Flag("connect", "Connect name (default: all connects)").String()
+	queueUrl               = queueCommand.Flag("server-url", "Server URL (default: spec.server.addr)").String()
+	queueListCommand       = queueCommand.Command("list", "List pending and in-flight events").Default()
+	queuePauseCommand      = queueCommand.Command("pause", "Pause dispatching events")
+	queuePrioritizeCommand = queueCommand.Command("prioritize", "Move pending event to the front")
+	queuePrioritizeId      = queuePrioritizeCommand.Arg("id", "Event id").Required().String()
+	queueRemoveCommand     = queueCommand.Command("remove", "Remove pending event")
+	queueRemoveId          = queueRemoveCommand.Arg("id", "Event id").Required().String()
+	queueResumeCommand     = queueCommand.Command("resume", "Resume dispatching events")
 )
 
+// dryRunResult to store dry run output of one event
+type dryRunResult struct {
+	Error    string                `json:"error,omitempty"`
+	Explains []trigger.Explanation `json:"explains,omitempty"`
+	Line     int                   `json:"line"`
+	Matched  bool                  `json:"matched"`
+	Matches  []trigger.Match       `json:"matches"`
+	Type     string                `json:"type"`
+}
+
 func Run(ctx context.Context) error {
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	command := kingpin.MustParse(app.Parse(os.Args[1:]))
 
 	logger, err := initLogger(ctx, *logLevel)
 	if err != nil {
@@ -47,43 +99,27 @@ func Run(ctx context.Context) error {
 		return errors.Wrap(err, "failed to init config")
 	}
 
-	flt, err := initFilter(ctx, logger, cfg)
-	if err != nil {
-		return errors.Wrap(err, "failed to init filter")
-	}
-
-	pb, err := initPlayback(ctx, logger, cfg)
-	if err != nil {
-		return errors.Wrap(err, "failed to init playback")
-	}
-
-	qy, err := initQuery(ctx, logger, cfg)
-	if err != nil {
-		return errors.Wrap(err, "failed to init query")
-	}
-
-	mq, err := initQueue(ctx, logger, cfg)
-	if err != nil {
-		return errors.Wrap(err, "failed to init queue")
-	}
-
-	rpt, err := initReport(ctx, logger, cfg)
-	if err != nil {
-		return errors.Wrap(err, "failed to init report")
-	}
-
-	wd, err := initWatchdog(ctx, logger, cfg)
-	if err != nil {
-		return errors.Wrap(err, "failed to init watchdog")
-	}
-
-	t, err := initTrigger(ctx, logger, cfg, flt, pb, qy, mq, rpt, wd)
-	if err != nil {
-		return errors.Wrap(err, "failed to init trigger")
-	}
-
-	if err := runTrigger(ctx, logger, t); err != nil {
-		return errors.Wrap(err, "failed to run trigger")
+	switch command {
+	case dryRunCommand.FullCommand():
+		return runDryRun(ctx, logger, cfg, *dryRunEvents, *dryRunExplain, os.Stdout)
+	case reinjectCommand.FullCommand():
+		return runReinject(ctx, logger, cfg, *reinjectUrl, os.Stdout)
+	case queueListCommand.FullCommand():
+		return runQueue(ctx, logger, cfg, *queueUrl, *queueConnect, http.MethodGet, "", os.Stdout)
+	case queuePauseCommand.FullCommand():
+		return runQueue(ctx, logger, cfg, *queueUrl, *queueConnect, http.MethodPost, "/pause", os.Stdout)
+	case queuePrioritizeCommand.FullCommand():
+		return runQueue(ctx, logger, cfg, *queueUrl, *queueConnect, http.MethodPost,
+			"/events/"+neturl.PathEscape(*queuePrioritizeId)+"/prioritize", os.Stdout)
+	case queueRemoveCommand.FullCommand():
+		return runQueue(ctx, logger, cfg, *queueUrl, *queueConnect, http.MethodDelete,
+			"/events/"+neturl.PathEscape(*queueRemoveId), os.Stdout)
+	case queueResumeCommand.FullCommand():
+		return runQueue(ctx, logger, cfg, *queueUrl, *queueConnect, http.MethodPost, "/resume", os.Stdout)
+	case validateCommand.FullCommand():
+		return runValidate(ctx, logger, cfg, os.Stdout)
+	case runCommand.FullCommand():
+		return runStart(ctx, logger, cfg)
 	}
 
 	return nil
@@ -96,7 +132,7 @@ func initLogger(_ context.Context, level string) (hclog.Logger, error) {
 	}), nil
 }
 
-func initConfig(_ context.Context, logger hclog.Logger, name string) (*config.Config, error) {
+func initConfig(ctx context.Context, logger hclog.Logger, name string) (*config.Config, error) {
 	logger.Debug("cmd: initConfig")
 
 	c := config.New()
@@ -112,13 +148,88 @@ func initConfig(_ context.Context, logger hclog.Logger, name string) (*config.Co
 
 	buf, _ := io.ReadAll(fi)
 
-	if err := yaml.Unmarshal(buf, c); err != nil {
-		return c, errors.Wrap(err, "failed to unmarshal")
+	v := validate.DefaultConfig()
+	v.Logger = logger
+
+	c, err = validate.New(ctx, v).Run(ctx, buf)
+	if err != nil {
+		return c, errors.Wrap(err, "failed to validate")
 	}
 
 	return c, nil
 }
 
+func initConnects(_ context.Context, logger hclog.Logger, cfg *config.Config) []*config.Config {
+	logger.Debug("cmd: initConnects")
+
+	if len(cfg.Spec.Connects) == 0 {
+		return []*config.Config{cfg}
+	}
+
+	buf := make([]*config.Config, len(cfg.Spec.Connects))
+
+	for i := range cfg.Spec.Connects {
+		c := *cfg
+		c.Spec.Connect = cfg.Spec.Connects[i]
+		// Playback is enabled per connect only, since events API of one server is invalid for another
+		c.Spec.Playback = c.Spec.Connect.Playback
+		buf[i] = &c
+	}
+
+	return buf
+}
+
+func initServer(ctx context.Context, logger hclog.Logger, cfg *config.Config, dl deadletter.DeadLetter, mq queue.Queue,
+	sh connect.Ssh, wd watchdog.Watchdog, wk worker.Worker) (trigger.Trigger, error) {
+	logger.Debug("cmd: initServer")
+
+	flt, err := initFilter(ctx, logger, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init filter")
+	}
+
+	pb, err := initPlayback(ctx, logger, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init playback")
+	}
+
+	qy, err := initQuery(ctx, logger, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init query")
+	}
+
+	qt, err := initQuiet(ctx, logger, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init quiet")
+	}
+
+	rpt, err := initReport(ctx, logger, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init report")
+	}
+
+	t, err := initTrigger(ctx, logger, cfg, dl, flt, pb, qy, mq, qt, rpt, sh, wd, wk)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init trigger")
+	}
+
+	return t, nil
+}
+
+func initBuild(ctx context.Context, logger hclog.Logger, cfg *config.Config) (build.Build, error) {
+	logger.Debug("cmd: initBuild")
+
+	c := build.DefaultConfig()
+	if c == nil {
+		return nil, errors.New("failed to config")
+	}
+
+	c.Config = *cfg
+	c.Logger = logger
+
+	return build.New(ctx, c), nil
+}
+
 func initConnect(ctx context.Context, logger hclog.Logger, cfg *config.Config) (connect.Rest, connect.Ssh, error) {
 	logger.Debug("cmd: initConnect")
 
@@ -141,6 +252,36 @@ func initConnect(ctx context.Context, logger hclog.Logger, cfg *config.Config) (
 	return connect.RestNew(ctx, rc), connect.SshNew(ctx, sc), nil
 }
 
+func initDeadLetter(ctx context.Context, logger hclog.Logger, cfg *config.Config) (deadletter.DeadLetter, error) {
+	logger.Debug("cmd: initDeadLetter")
+
+	c := deadletter.DefaultConfig()
+	if c == nil {
+		return nil, errors.New("failed to config")
+	}
+
+	c.Config = *cfg
+	c.Logger = logger
+
+	return deadletter.New(ctx, c), nil
+}
+
+func initDispatch(ctx context.Context, logger hclog.Logger, cfg *config.Config,
+	result chan *dispatch.Result) (dispatch.Dispatch, error) {
+	logger.Debug("cmd: initDispatch")
+
+	c := dispatch.DefaultConfig()
+	if c == nil {
+		return nil, errors.New("failed to config")
+	}
+
+	c.Config = *cfg
+	c.Logger = logger
+	c.Result = result
+
+	return dispatch.New(ctx, c), nil
+}
+
 func initFilter(ctx context.Context, logger hclog.Logger, cfg *config.Config) (filter.Filter, error) {
 	logger.Debug("cmd: initFilter")
 
@@ -197,6 +338,20 @@ func initQueue(ctx context.Context, logger hclog.Logger, cfg *config.Config) (qu
 	return queue.New(ctx, c), nil
 }
 
+func initQuiet(ctx context.Context, logger hclog.Logger, cfg *config.Config) (queue.Quiet, error) {
+	logger.Debug("cmd: initQuiet")
+
+	c := queue.DefaultConfig()
+	if c == nil {
+		return nil, errors.New("failed to config")
+	}
+
+	c.Config = *cfg
+	c.Logger = logger
+
+	return queue.NewQuiet(ctx, c), nil
+}
+
 func initReport(ctx context.Context, logger hclog.Logger, cfg *config.Config) (report.Report, error) {
 	logger.Debug("cmd: initReport")
 
@@ -211,32 +366,98 @@ func initReport(ctx context.Context, logger hclog.Logger, cfg *config.Config) (r
 	return report.New(ctx, c), nil
 }
 
-func initWatchdog(ctx context.Context, logger hclog.Logger, cfg *config.Config) (watchdog.Watchdog, error) {
-	logger.Debug("cmd: initWatchdog")
+func initReview(ctx context.Context, logger hclog.Logger, cfg *config.Config, bs build.Build) (review.Review, error) {
+	logger.Debug("cmd: initReview")
 
-	var err error
+	c := review.DefaultConfig()
+	if c == nil {
+		return nil, errors.New("failed to config")
+	}
 
-	c := watchdog.DefaultConfig()
+	c.Build = bs
+	c.Config = *cfg
+	c.Logger = logger
+	c.Rests = map[string]connect.Rest{}
+
+	for _, item := range initConnects(ctx, logger, cfg) {
+		rest, _, err := initConnect(ctx, logger, item)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to init connect")
+		}
+		c.Rests[item.Spec.Connect.Name] = rest
+	}
+
+	return review.New(ctx, c), nil
+}
+
+func initHttp(ctx context.Context, logger hclog.Logger, cfg *config.Config, bs build.Build, queues map[string]queue.Queue,
+	watchdogs map[string]watchdog.Watchdog, workers map[string]worker.Worker, result chan *dispatch.Result) (server.Server, error) {
+	logger.Debug("cmd: initHttp")
+
+	c := server.DefaultConfig()
 	if c == nil {
 		return nil, errors.New("failed to config")
 	}
 
+	c.Build = bs
 	c.Config = *cfg
 	c.Logger = logger
+	c.Queues = queues
+	c.Result = result
+	c.Watchdogs = watchdogs
+	c.Workers = workers
 
-	_, c.Ssh, err = initConnect(ctx, logger, cfg)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to init connect")
+	return server.New(ctx, c), nil
+}
+
+func initWatchdog(ctx context.Context, logger hclog.Logger, cfg *config.Config, sh connect.Ssh) (watchdog.Watchdog, error) {
+	logger.Debug("cmd: initWatchdog")
+
+	c := watchdog.DefaultConfig()
+	if c == nil {
+		return nil, errors.New("failed to config")
 	}
 
+	c.Config = *cfg
+	c.Logger = logger
+	c.Ssh = sh
+
 	return watchdog.New(ctx, c), nil
 }
 
-func initTrigger(ctx context.Context, logger hclog.Logger, cfg *config.Config, flt filter.Filter, pb playback.Playback, qy query.Query,
-	mq queue.Queue, rpt report.Report, wd watchdog.Watchdog) (trigger.Trigger, error) {
-	logger.Debug("cmd: initTrigger")
+func initWorker(ctx context.Context, logger hclog.Logger, cfg *config.Config) (worker.Worker, error) {
+	logger.Debug("cmd: initWorker")
+
+	c := worker.DefaultConfig()
+	if c == nil {
+		return nil, errors.New("failed to config")
+	}
+
+	c.Config = *cfg
+	c.Logger = logger
+
+	return worker.New(ctx, c), nil
+}
 
-	var err error
+func initSupersede(ctx context.Context, logger hclog.Logger, cfg *config.Config, bs build.Build) (supersede.Supersede, error) {
+	logger.Debug("cmd: initSupersede")
+
+	c := supersede.DefaultConfig()
+	if c == nil {
+		return nil, errors.New("failed to config")
+	}
+
+	c.Build = bs
+	c.Config = *cfg
+	c.Logger = logger
+
+	return supersede.New(ctx, c), nil
+}
+
+func initTrigger(ctx context.Context, logger hclog.Logger, cfg *config.Config, dl deadletter.DeadLetter, flt filter.Filter,
+	pb playback.Playback, qy query.Query, mq queue.Queue, qt queue.Quiet, rpt report.Report, sh connect.Ssh, wd watchdog.Watchdog,
+	wk worker.Worker) (trigger.Trigger, error) {
+	logger.Debug("cmd: initTrigger")
 
 	c := trigger.DefaultConfig()
 	if c == nil {
@@ -244,45 +465,491 @@ func initTrigger(ctx context.Context, logger hclog.Logger, cfg *config.Config, f
 	}
 
 	c.Config = *cfg
+	c.DeadLetter = dl
 	c.Filter = flt
 	c.Logger = logger
 	c.Playback = pb
 	c.Query = qy
 	c.Queue = mq
+	c.Quiet = qt
 	c.Report = rpt
+	c.Ssh = sh
 	c.Watchdog = wd
+	c.Worker = wk
 
-	_, c.Ssh, err = initConnect(ctx, logger, cfg)
+	return trigger.New(ctx, c), nil
+}
+
+func runStart(ctx context.Context, logger hclog.Logger, cfg *config.Config) error {
+	logger.Debug("cmd: runStart")
+
+	result := make(chan *dispatch.Result)
+
+	dp, err := initDispatch(ctx, logger, cfg, result)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to init connect")
+		return errors.Wrap(err, "failed to init dispatch")
 	}
 
-	return trigger.New(ctx, c), nil
+	bs, err := initBuild(ctx, logger, cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to init build")
+	}
+
+	rv, err := initReview(ctx, logger, cfg, bs)
+	if err != nil {
+		return errors.Wrap(err, "failed to init review")
+	}
+
+	sp, err := initSupersede(ctx, logger, cfg, bs)
+	if err != nil {
+		return errors.Wrap(err, "failed to init supersede")
+	}
+
+	dl, err := initDeadLetter(ctx, logger, cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to init deadletter")
+	}
+
+	var triggers []trigger.Trigger
+
+	queues := map[string]queue.Queue{}
+	watchdogs := map[string]watchdog.Watchdog{}
+	workers := map[string]worker.Worker{}
+
+	for _, item := range initConnects(ctx, logger, cfg) {
+		l := logger
+		if len(cfg.Spec.Connects) != 0 {
+			l = logger.Named(item.Spec.Connect.Name)
+		}
+		mq, err := initQueue(ctx, l, item)
+		if err != nil {
+			return errors.Wrap(err, "failed to init queue "+item.Spec.Connect.Name)
+		}
+		wk, err := initWorker(ctx, l, item)
+		if err != nil {
+			return errors.Wrap(err, "failed to init worker "+item.Spec.Connect.Name)
+		}
+		// Watchdog checks and recovers the connection of trigger
+		_, sh, err := initConnect(ctx, l, item)
+		if err != nil {
+			return errors.Wrap(err, "failed to init connect "+item.Spec.Connect.Name)
+		}
+		wd, err := initWatchdog(ctx, l, item, sh)
+		if err != nil {
+			return errors.Wrap(err, "failed to init watchdog "+item.Spec.Connect.Name)
+		}
+		t, err := initServer(ctx, l, item, dl, mq, sh, wd, wk)
+		if err != nil {
+			return errors.Wrap(err, "failed to init server "+item.Spec.Connect.Name)
+		}
+		triggers = append(triggers, t)
+		queues[item.Spec.Connect.Name] = mq
+		watchdogs[item.Spec.Connect.Name] = wd
+		workers[item.Spec.Connect.Name] = wk
+	}
+
+	srv, err := initHttp(ctx, logger, cfg, bs, queues, watchdogs, workers, result)
+	if err != nil {
+		return errors.Wrap(err, "failed to init http")
+	}
+
+	if err := runTrigger(ctx, logger, triggers, dp, bs, rv, sp, srv, result); err != nil {
+		return errors.Wrap(err, "failed to run trigger")
+	}
+
+	return nil
 }
 
-func runTrigger(ctx context.Context, logger hclog.Logger, t trigger.Trigger) error {
+// nolint:funlen
+func runTrigger(ctx context.Context, logger hclog.Logger, triggers []trigger.Trigger, dp dispatch.Dispatch, bs build.Build,
+	rv review.Review, sp supersede.Supersede, srv server.Server, result chan *dispatch.Result) error {
 	logger.Debug("cmd: runTrigger")
 
-	if err := t.Init(ctx); err != nil {
-		return errors.Wrap(err, "failed to init")
+	if err := rv.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init review")
+	}
+
+	defer func() {
+		_ = rv.Deinit(ctx)
+	}()
+
+	if err := bs.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init build")
+	}
+
+	defer func() {
+		_ = bs.Deinit(ctx)
+	}()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for item := range result {
+			rec, err := bs.Update(ctx, item)
+			if errors.Is(err, build.ErrTransition) {
+				logger.Debug("cmd: runTrigger", "id", item.Request.Id, "status", item.Status, "error", err.Error())
+				continue
+			}
+			if err != nil {
+				logger.Warn("cmd: runTrigger", "id", item.Request.Id, "status", item.Status, "error", err.Error())
+				continue
+			}
+			if err := rv.Run(ctx, rec); err != nil {
+				logger.Error("cmd: runTrigger", "error", err.Error())
+			}
+		}
+	}()
+
+	// Close result after dispatchers and server finish to drain pending build results
+	defer func() {
+		close(result)
+		<-done
+	}()
+
+	if err := sp.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init supersede")
 	}
 
-	param := make(chan map[string]string)
+	defer func() {
+		_ = sp.Deinit(ctx)
+	}()
+
+	if err := srv.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init server")
+	}
 
-	_ = t.Run(ctx, nil, nil, param)
+	defer func() {
+		_ = srv.Deinit(ctx)
+	}()
+
+	if err := dp.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init dispatch")
+	}
+
+	defer func() {
+		_ = dp.Deinit(ctx)
+	}()
+
+	for i := range triggers {
+		if err := triggers[i].Init(ctx); err != nil {
+			return errors.Wrap(err, "failed to init")
+		}
+	}
+
+	defer func() {
+		for i := range triggers {
+			_ = triggers[i].Deinit(ctx)
+		}
+	}()
+
+	c, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	param := make(chan *dispatch.Request)
+
+	var wg sync.WaitGroup
+
+	for i := range triggers {
+		p := make(chan *dispatch.Request)
+		if err := triggers[i].Run(c, nil, p); err != nil {
+			logger.Error("cmd: runTrigger", "error", err.Error())
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range p {
+				param <- item
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(param)
+	}()
 
 	_signal := make(chan os.Signal, 1)
 	signal.Notify(_signal, os.Interrupt)
 
 	go func() {
 		<-_signal
-		defer close(param)
-		_ = t.Deinit(ctx)
+		cancel()
 	}()
 
 	for item := range param {
-		logger.Info("cmd: runTrigger", item)
+		if item.Cancel {
+			runCancel(ctx, logger, dp, sp, item, result)
+			continue
+		}
+		if sp.Stale(ctx, item) {
+			logger.Info("cmd: runTrigger", "job", item.Job, "change", item.Event.Change.Number,
+				"patchset", item.Event.PatchSet.Number, "skip", "superseded")
+			continue
+		}
+		if _, err := bs.Add(ctx, item); err != nil {
+			logger.Error("cmd: runTrigger", "error", err.Error())
+			continue
+		}
+		if err := dp.Run(ctx, item); err != nil {
+			logger.Error("cmd: runTrigger", "error", err.Error())
+			result <- &dispatch.Result{Dispatch: item.Dispatch, Request: item, Status: dispatch.StatusFailed}
+		}
+	}
+
+	return nil
+}
+
+func runCancel(ctx context.Context, logger hclog.Logger, dp dispatch.Dispatch, sp supersede.Supersede, req *dispatch.Request,
+	result chan *dispatch.Result) {
+	logger.Debug("cmd: runCancel")
+
+	records, err := sp.Run(ctx, req)
+	if err != nil {
+		logger.Error("cmd: runCancel", "error", err.Error())
+		return
+	}
+
+	for _, item := range records {
+		logger.Info("cmd: runCancel", "job", item.Job, "change", item.Change, "patchset", item.Patchset, "event", req.Event.Type)
+		if err := dp.Cancel(ctx, item.Request); err != nil {
+			logger.Error("cmd: runCancel", "error", err.Error())
+		}
+		result <- &dispatch.Result{Dispatch: item.Dispatch, Request: item.Request, Status: dispatch.StatusAborted, Url: item.Url}
+	}
+}
+
+func runReinject(ctx context.Context, logger hclog.Logger, cfg *config.Config, url string, out io.Writer) error {
+	logger.Debug("cmd: runReinject")
+
+	if url == "" {
+		url = serverUrl(cfg.Spec.Server.Addr)
+	}
+
+	if url == "" {
+		return errors.New("invalid server url")
+	}
+
+	dl, err := initDeadLetter(ctx, logger, cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to init deadletter")
+	}
+
+	client := &http.Client{Timeout: reinjectTimeout}
+
+	// Events failed to re-inject are kept in dead letter
+	count, total, err := dl.Drain(ctx, func(letter *deadletter.Letter) error {
+		return postEvent(ctx, client, url, cfg.Spec.Server.Token, letter)
+	})
+
+	_, _ = fmt.Fprintf(out, "%d of %d events re-injected\n", count, total)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to drain deadletter")
 	}
 
 	return nil
 }
+
+func postEvent(ctx context.Context, client *http.Client, url, token string, letter *deadletter.Letter) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(url, "/")+reinjectPath+"?connect="+
+		neturl.QueryEscape(letter.Connect), strings.NewReader(letter.Event))
+	if err != nil {
+		return errors.Wrap(err, "failed to new request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	if rsp.StatusCode != http.StatusAccepted {
+		return errors.New("invalid status " + rsp.Status)
+	}
+
+	return nil
+}
+
+// runQueue to send one request to queue API of running trigger, and events listed are printed in JSON
+func runQueue(ctx context.Context, logger hclog.Logger, cfg *config.Config, url, connect, method, path string, out io.Writer) error {
+	logger.Debug("cmd: runQueue")
+
+	if url == "" {
+		url = serverUrl(cfg.Spec.Server.Addr)
+	}
+
+	if url == "" {
+		return errors.New("invalid server url")
+	}
+
+	// Events are managed in the queue of single connect
+	if connect == "" && len(cfg.Spec.Connects) == 0 && strings.HasPrefix(path, "/events/") {
+		connect = cfg.Spec.Connect.Name
+	}
+
+	url = strings.TrimSuffix(url, "/") + queuePath + path
+
+	if connect != "" || strings.HasPrefix(path, "/events/") {
+		url += "?connect=" + neturl.QueryEscape(connect)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "failed to new request")
+	}
+
+	if cfg.Spec.Server.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Spec.Server.Token)
+	}
+
+	client := &http.Client{Timeout: reinjectTimeout}
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNoContent {
+		return errors.New("invalid status " + rsp.Status)
+	}
+
+	if rsp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	var buf map[string]queue.Snapshot
+
+	if err := json.NewDecoder(rsp.Body).Decode(&buf); err != nil {
+		return errors.Wrap(err, "failed to decode")
+	}
+
+	b, err := json.MarshalIndent(buf, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal")
+	}
+
+	_, _ = fmt.Fprintln(out, string(b))
+
+	return nil
+}
+
+func serverUrl(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func runValidate(_ context.Context, logger hclog.Logger, _ *config.Config, out io.Writer) error {
+	logger.Debug("cmd: runValidate")
+
+	_, _ = fmt.Fprintln(out, "config is valid")
+
+	return nil
+}
+
+func runDryRun(ctx context.Context, logger hclog.Logger, cfg *config.Config, name string, explain bool, out io.Writer) error {
+	logger.Debug("cmd: runDryRun")
+
+	var triggers []trigger.Trigger
+
+	for _, item := range initConnects(ctx, logger, cfg) {
+		flt, err := initFilter(ctx, logger, item)
+		if err != nil {
+			return errors.Wrap(err, "failed to init filter")
+		}
+		if err := flt.Init(ctx); err != nil {
+			return errors.Wrap(err, "failed to init filter")
+		}
+		rpt, err := initReport(ctx, logger, item)
+		if err != nil {
+			return errors.Wrap(err, "failed to init report")
+		}
+		if err := rpt.Init(ctx); err != nil {
+			return errors.Wrap(err, "failed to init report")
+		}
+		t, err := initTrigger(ctx, logger, item, nil, flt, nil, nil, nil, nil, rpt, nil, nil, nil)
+		if err != nil {
+			return errors.Wrap(err, "failed to init trigger")
+		}
+		triggers = append(triggers, t)
+	}
+
+	fi, err := os.Open(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to open")
+	}
+
+	defer func() {
+		_ = fi.Close()
+	}()
+
+	enc := json.NewEncoder(out)
+	line := 0
+
+	scan := bufio.NewScanner(fi)
+	scan.Buffer(make([]byte, 0, dryRunBuffer), dryRunBuffer)
+
+	for scan.Scan() {
+		line++
+		if strings.TrimSpace(scan.Text()) == "" {
+			continue
+		}
+		r := dryRunResult{
+			Line:    line,
+			Matches: []trigger.Match{},
+		}
+		e := events.Event{}
+		if err := json.Unmarshal(scan.Bytes(), &e); err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Type = e.Type
+		}
+		for i := range triggers {
+			if r.Error != "" {
+				break
+			}
+			m, err := triggers[i].DryRun(ctx, scan.Text())
+			if err != nil {
+				r.Error = err.Error()
+				break
+			}
+			r.Matches = append(r.Matches, m...)
+			if !explain {
+				continue
+			}
+			e, err := triggers[i].Explain(ctx, scan.Text())
+			if err != nil {
+				r.Error = err.Error()
+				break
+			}
+			r.Explains = append(r.Explains, e...)
+		}
+		r.Matched = len(r.Matches) != 0
+		if err := enc.Encode(r); err != nil {
+			return errors.Wrap(err, "failed to encode")
+		}
+	}
+
+	return scan.Err()
+}
@@ -1,15 +1,22 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
 
 	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/deadletter"
 )
 
 func testInitConfig() *config.Config {
@@ -49,6 +56,14 @@ func TestInitConfig(t *testing.T) {
 	assert.Equal(t, nil, err)
 }
 
+func TestInitBuild(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initBuild(context.Background(), logger, cfg)
+	assert.Equal(t, nil, err)
+}
+
 func TestInitConnect(t *testing.T) {
 	logger, _ := initLogger(context.Background(), level)
 	cfg := testInitConfig()
@@ -57,6 +72,51 @@ func TestInitConnect(t *testing.T) {
 	assert.Equal(t, nil, err)
 }
 
+func TestInitDeadLetter(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initDeadLetter(context.Background(), logger, cfg)
+	assert.Equal(t, nil, err)
+}
+
+func TestInitDispatch(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initDispatch(context.Background(), logger, cfg, nil)
+	assert.Equal(t, nil, err)
+}
+
+func TestInitConnects(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	buf := initConnects(context.Background(), logger, cfg)
+	assert.Equal(t, 1, len(buf))
+	assert.Equal(t, cfg, buf[0])
+
+	cfg.Spec.Connects = []config.Connect{
+		{Name: "gerrit1"},
+		{Name: "gerrit2", Playback: config.Playback{EventsApi: "http://gerrit2/events"}},
+	}
+
+	buf = initConnects(context.Background(), logger, cfg)
+	assert.Equal(t, 2, len(buf))
+	assert.Equal(t, "gerrit1", buf[0].Spec.Connect.Name)
+	assert.Equal(t, "", buf[0].Spec.Playback.EventsApi)
+	assert.Equal(t, "gerrit2", buf[1].Spec.Connect.Name)
+	assert.Equal(t, "http://gerrit2/events", buf[1].Spec.Playback.EventsApi)
+}
+
+func TestInitServer(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initServer(context.Background(), logger, cfg, nil, nil, nil, nil, nil)
+	assert.Equal(t, nil, err)
+}
+
 func TestInitFilter(t *testing.T) {
 	logger, _ := initLogger(context.Background(), level)
 	cfg := testInitConfig()
@@ -89,6 +149,14 @@ func TestInitQueue(t *testing.T) {
 	assert.Equal(t, nil, err)
 }
 
+func TestInitQuiet(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initQuiet(context.Background(), logger, cfg)
+	assert.Equal(t, nil, err)
+}
+
 func TestInitReport(t *testing.T) {
 	logger, _ := initLogger(context.Background(), level)
 	cfg := testInitConfig()
@@ -97,11 +165,43 @@ func TestInitReport(t *testing.T) {
 	assert.Equal(t, nil, err)
 }
 
+func TestInitHttp(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initHttp(context.Background(), logger, cfg, nil, nil, nil, nil, nil)
+	assert.Equal(t, nil, err)
+}
+
+func TestInitReview(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initReview(context.Background(), logger, cfg, nil)
+	assert.Equal(t, nil, err)
+}
+
 func TestInitWatchdog(t *testing.T) {
 	logger, _ := initLogger(context.Background(), level)
 	cfg := testInitConfig()
 
-	_, err := initWatchdog(context.Background(), logger, cfg)
+	_, err := initWatchdog(context.Background(), logger, cfg, nil)
+	assert.Equal(t, nil, err)
+}
+
+func TestInitWorker(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initWorker(context.Background(), logger, cfg)
+	assert.Equal(t, nil, err)
+}
+
+func TestInitSupersede(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	_, err := initSupersede(context.Background(), logger, cfg, nil)
 	assert.Equal(t, nil, err)
 }
 
@@ -109,6 +209,143 @@ func TestInitTrigger(t *testing.T) {
 	logger, _ := initLogger(context.Background(), level)
 	cfg := testInitConfig()
 
-	_, err := initTrigger(context.Background(), logger, cfg, nil, nil, nil, nil, nil, nil)
+	_, err := initTrigger(context.Background(), logger, cfg, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	assert.Equal(t, nil, err)
+}
+
+func TestRunValidate(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	var out bytes.Buffer
+
+	err := runValidate(context.Background(), logger, cfg, &out)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "config is valid\n", out.String())
+}
+
+func TestRunDryRun(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+
+	var out bytes.Buffer
+
+	err := runDryRun(context.Background(), logger, cfg, "invalid.jsonl", false, &out)
+	assert.NotEqual(t, nil, err)
+
+	err = runDryRun(context.Background(), logger, cfg, "../test/events/events.jsonl", true, &out)
+	assert.Equal(t, nil, err)
+
+	var buf []dryRunResult
+
+	dec := json.NewDecoder(&out)
+
+	for dec.More() {
+		var r dryRunResult
+		_ = dec.Decode(&r)
+		buf = append(buf, r)
+	}
+
+	assert.Equal(t, 4, len(buf))
+
+	assert.Equal(t, true, buf[0].Matched)
+	assert.Equal(t, "build", buf[0].Matches[0].Job)
+	assert.Equal(t, []int{1}, buf[0].Matches[0].Events)
+	assert.Equal(t, []int{0}, buf[0].Matches[0].Projects)
+	assert.Equal(t, "22", buf[0].Matches[0].Params["GERRIT_CHANGE_NUMBER"])
+
+	assert.Equal(t, true, buf[1].Matched)
+	assert.Equal(t, "notify", buf[1].Matches[0].Job)
+
+	assert.Equal(t, false, buf[2].Matched)
+	assert.Equal(t, 0, len(buf[2].Matches))
+	assert.Equal(t, 2, len(buf[2].Explains))
+	assert.Equal(t, false, buf[2].Explains[0].Trace.Matched)
+
+	assert.Equal(t, 5, buf[3].Line)
+	assert.NotEqual(t, "", buf[3].Error)
+}
+
+func TestRunReinject(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+	ctx := context.Background()
+
+	var body []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, reinjectPath, r.URL.Path)
+		assert.Equal(t, "Bearer "+cfg.Spec.Server.Token, r.Header.Get("Authorization"))
+		buf, _ := io.ReadAll(r.Body)
+		if r.URL.Query().Get("connect") != "gerrit" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body = append(body, string(buf))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	defer srv.Close()
+
+	cfg.Spec.DeadLetter.Path = filepath.Join(t.TempDir(), "deadletter.jsonl")
+
+	dl, _ := initDeadLetter(ctx, logger, cfg)
+	_ = dl.Put(ctx, &deadletter.Letter{Connect: "gerrit", Event: `{"type":"patchset-created"}`})
+	_ = dl.Put(ctx, &deadletter.Letter{Connect: "invalid", Event: `{"type":"comment-added"}`})
+
+	var out bytes.Buffer
+
+	err := runReinject(ctx, logger, cfg, srv.URL, &out)
 	assert.Equal(t, nil, err)
+	assert.Equal(t, "1 of 2 events re-injected\n", out.String())
+	assert.Equal(t, []string{`{"type":"patchset-created"}`}, body)
+
+	var buf []deadletter.Letter
+
+	_, _, _ = dl.Drain(ctx, func(letter *deadletter.Letter) error {
+		buf = append(buf, *letter)
+		return nil
+	})
+	assert.Equal(t, 1, len(buf))
+	assert.Equal(t, "invalid", buf[0].Connect)
+
+	assert.Equal(t, "http://127.0.0.1:8090", serverUrl(":8090"))
+	assert.Equal(t, "", serverUrl(""))
+}
+
+func TestRunQueue(t *testing.T) {
+	logger, _ := initLogger(context.Background(), level)
+	cfg := testInitConfig()
+	ctx := context.Background()
+
+	var reqs []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs = append(reqs, r.Method+" "+r.URL.String())
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, _ = w.Write([]byte(`{"gerrit":{"inflight":[],"paused":true,"pending":[{"data":"{}","id":"1"}]}}`))
+	}))
+
+	defer srv.Close()
+
+	var out bytes.Buffer
+
+	err := runQueue(ctx, logger, cfg, srv.URL, "", http.MethodGet, "", &out)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, strings.Contains(out.String(), `"paused": true`))
+
+	err = runQueue(ctx, logger, cfg, srv.URL, "gerrit", http.MethodPost, "/pause", &out)
+	assert.Equal(t, nil, err)
+
+	err = runQueue(ctx, logger, cfg, srv.URL, "", http.MethodDelete, "/events/1", &out)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, []string{
+		"GET " + queuePath,
+		"POST " + queuePath + "/pause?connect=gerrit",
+		"DELETE " + queuePath + "/events/1?connect=" + cfg.Spec.Connect.Name,
+	}, reqs)
 }
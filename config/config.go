@@ -12,20 +12,29 @@ type MetaData struct {
 }
 
 type Spec struct {
-	Connect  Connect  `yaml:"connect"`
-	Queue    Queue    `yaml:"queue"`
-	Playback Playback `yaml:"playback"`
-	Report   Report   `yaml:"report"`
-	Trigger  Trigger  `yaml:"trigger"`
-	Watchdog Watchdog `yaml:"watchdog"`
+	Connect    Connect    `yaml:"connect"`
+	Connects   []Connect  `yaml:"connects"`
+	DeadLetter DeadLetter `yaml:"deadLetter"`
+	Dispatch   []Dispatch `yaml:"dispatch"`
+	History    History    `yaml:"history"`
+	Queue      Queue      `yaml:"queue"`
+	Playback   Playback   `yaml:"playback"`
+	Report     Report     `yaml:"report"`
+	Retry      Retry      `yaml:"retry"`
+	Review     Review     `yaml:"review"`
+	Server     Server     `yaml:"server"`
+	Trigger    Trigger    `yaml:"trigger"`
+	Watchdog   Watchdog   `yaml:"watchdog"`
+	Worker     Worker     `yaml:"worker"`
 }
 
 type Connect struct {
-	FrontendUrl string `yaml:"frontendUrl"`
-	Hostname    string `yaml:"hostname"`
-	Name        string `yaml:"name"`
-	Http        Http   `yaml:"http"`
-	Ssh         Ssh    `yaml:"ssh"`
+	FrontendUrl string   `yaml:"frontendUrl"`
+	Hostname    string   `yaml:"hostname"`
+	Name        string   `yaml:"name"`
+	Http        Http     `yaml:"http"`
+	Playback    Playback `yaml:"playback"`
+	Ssh         Ssh      `yaml:"ssh"`
 }
 
 type Http struct {
@@ -34,13 +43,69 @@ type Http struct {
 }
 
 type Ssh struct {
-	Keyfile         string `yaml:"keyfile"`
-	KeyfilePassword string `yaml:"keyfilePassword"`
-	Port            int    `yaml:"port"`
-	Username        string `yaml:"username"`
+	AuthMethods     []string `yaml:"authMethods"`
+	Certfile        string   `yaml:"certfile"`
+	Fingerprints    []string `yaml:"fingerprints"`
+	Keyfile         string   `yaml:"keyfile"`
+	KeyfilePassword string   `yaml:"keyfilePassword"`
+	KnownHosts      string   `yaml:"knownHosts"`
+	Port            int      `yaml:"port"`
+	Sessions        int      `yaml:"sessions"`
+	TimeoutSeconds  int      `yaml:"timeoutSeconds"`
+	TrustOnFirstUse bool     `yaml:"trustOnFirstUse"`
+	Username        string   `yaml:"username"`
+}
+
+type DeadLetter struct {
+	Path string `yaml:"path"`
+}
+
+type Dispatch struct {
+	Name    string  `yaml:"name"`
+	Type    string  `yaml:"type"`
+	Exec    Exec    `yaml:"exec"`
+	Jenkins Jenkins `yaml:"jenkins"`
+	Webhook Webhook `yaml:"webhook"`
+}
+
+type Exec struct {
+	Command        []string `yaml:"command"`
+	Concurrency    int      `yaml:"concurrency"`
+	Dir            string   `yaml:"dir"`
+	TimeoutSeconds int      `yaml:"timeoutSeconds"`
+}
+
+type Jenkins struct {
+	Job                 string `yaml:"job"`
+	PollSeconds         int    `yaml:"pollSeconds"`
+	QueueTimeoutSeconds int    `yaml:"queueTimeoutSeconds"`
+	Token               string `yaml:"token"`
+	Url                 string `yaml:"url"`
+	Username            string `yaml:"username"`
+}
+
+type Webhook struct {
+	Body           string            `yaml:"body"`
+	Headers        map[string]string `yaml:"headers"`
+	Retry          Retry             `yaml:"retry"`
+	Secret         string            `yaml:"secret"`
+	TimeoutSeconds int               `yaml:"timeoutSeconds"`
+	Urls           []string          `yaml:"urls"`
+}
+
+type Retry struct {
+	BackoffSeconds int `yaml:"backoffSeconds"`
+	Count          int `yaml:"count"`
+}
+
+type History struct {
+	RetentionSeconds int `yaml:"retentionSeconds"`
 }
 
 type Queue struct {
+	Path         string `yaml:"path"`
+	SegmentBytes int64  `yaml:"segmentBytes"`
+	Type         string `yaml:"type"`
 }
 
 type Playback struct {
@@ -50,11 +115,51 @@ type Playback struct {
 type Report struct {
 }
 
+type Review struct {
+	Aborted   Vote   `yaml:"aborted"`
+	Failed    Vote   `yaml:"failed"`
+	Label     string `yaml:"label"`
+	Started   Vote   `yaml:"started"`
+	Succeeded Vote   `yaml:"succeeded"`
+	Unstable  Vote   `yaml:"unstable"`
+}
+
+type Vote struct {
+	Message string `yaml:"message"`
+	Value   string `yaml:"value"`
+}
+
+type Server struct {
+	Addr  string `yaml:"addr"`
+	Token string `yaml:"token"`
+}
+
 type Trigger struct {
 	Events   []Event   `yaml:"events"`
+	Jobs     []Job     `yaml:"jobs"`
 	Projects []Project `yaml:"projects"`
 }
 
+type Job struct {
+	Cancel       bool      `yaml:"cancel"`
+	Connects     []string  `yaml:"connects"`
+	Dispatch     string    `yaml:"dispatch"`
+	Events       []Event   `yaml:"events"`
+	Name         string    `yaml:"name"`
+	Optional     bool      `yaml:"optional"`
+	Projects     []Project `yaml:"projects"`
+	QuietSeconds int       `yaml:"quietSeconds"`
+	Review       JobReview `yaml:"review"`
+}
+
+type JobReview struct {
+	Aborted   string `yaml:"aborted"`
+	Failed    string `yaml:"failed"`
+	Started   string `yaml:"started"`
+	Succeeded string `yaml:"succeeded"`
+	Unstable  string `yaml:"unstable"`
+}
+
 type Event struct {
 	CommentAdded                          CommentAdded                          `yaml:"commentAdded"`
 	CommentAddedContainsRegularExpression CommentAddedContainsRegularExpression `yaml:"commentAddedContainsRegularExpression"`
@@ -99,6 +204,12 @@ type Watchdog struct {
 	TimeoutSeconds int `yaml:"timeoutSeconds"`
 }
 
+type Worker struct {
+	Count     int    `yaml:"count"`
+	QueueSize int    `yaml:"queueSize"`
+	Shard     string `yaml:"shard"`
+}
+
 var (
 	Build   string
 	Version string
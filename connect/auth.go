@@ -0,0 +1,183 @@
+package connect
+
+import (
+	"net"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	cryptoSsh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+const (
+	authAgent   = "agent"
+	authKeyfile = "keyfile"
+	authSock    = "SSH_AUTH_SOCK"
+)
+
+// auth to offer signers of auth methods in order, all as one publickey method
+// since the client tries each method name only once
+type auth struct {
+	cfg     *config.Ssh
+	conn    net.Conn
+	logger  hclog.Logger
+	methods []string
+	mutex   sync.Mutex
+	signers []cryptoSsh.Signer
+}
+
+func newAuth(cfg *config.Ssh, logger hclog.Logger) (*auth, error) {
+	a := &auth{
+		cfg:     cfg,
+		logger:  logger,
+		methods: cfg.AuthMethods,
+	}
+
+	// Use keyfile and then agent if available by default
+	if len(a.methods) == 0 {
+		if cfg.Keyfile != "" {
+			a.methods = append(a.methods, authKeyfile)
+		}
+		if os.Getenv(authSock) != "" {
+			a.methods = append(a.methods, authAgent)
+		}
+	}
+
+	if len(a.methods) == 0 {
+		return nil, errors.New("no auth method, set keyfile or " + authSock)
+	}
+
+	for _, item := range a.methods {
+		switch item {
+		case authAgent:
+			if os.Getenv(authSock) == "" {
+				return nil, errors.New("invalid agent, " + authSock + " not set")
+			}
+		case authKeyfile:
+			signers, err := a.keyfile()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to load keyfile")
+			}
+			a.signers = signers
+		default:
+			return nil, errors.New("invalid auth method " + item)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *auth) method() cryptoSsh.AuthMethod {
+	return cryptoSsh.PublicKeysCallback(a.callback)
+}
+
+func (a *auth) callback() ([]cryptoSsh.Signer, error) {
+	var buf []cryptoSsh.Signer
+
+	for _, item := range a.methods {
+		switch item {
+		case authAgent:
+			signers, err := a.agent()
+			if err != nil {
+				// Keep other methods working without agent
+				a.logger.Warn("auth: callback", "error", err.Error())
+				continue
+			}
+			buf = append(buf, signers...)
+		case authKeyfile:
+			buf = append(buf, a.signers...)
+		}
+	}
+
+	return buf, nil
+}
+
+// keyfile to load private key, with certificate signer offered first if certfile is set
+func (a *auth) keyfile() ([]cryptoSsh.Signer, error) {
+	key, err := os.ReadFile(a.cfg.Keyfile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file")
+	}
+
+	var signer cryptoSsh.Signer
+
+	if a.cfg.KeyfilePassword != "" {
+		signer, err = cryptoSsh.ParsePrivateKeyWithPassphrase(key, []byte(a.cfg.KeyfilePassword))
+	} else {
+		signer, err = cryptoSsh.ParsePrivateKey(key)
+	}
+
+	if err != nil {
+		var missing *cryptoSsh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, errors.New("failed to parse key, keyfilePassword required")
+		}
+		return nil, errors.Wrap(err, "failed to parse key")
+	}
+
+	if a.cfg.Certfile == "" {
+		return []cryptoSsh.Signer{signer}, nil
+	}
+
+	buf, err := os.ReadFile(a.cfg.Certfile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cert")
+	}
+
+	pub, _, _, _, err := cryptoSsh.ParseAuthorizedKey(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse cert")
+	}
+
+	cert, ok := pub.(*cryptoSsh.Certificate)
+	if !ok {
+		return nil, errors.New("invalid cert")
+	}
+
+	certSigner, err := cryptoSsh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to new cert signer")
+	}
+
+	return []cryptoSsh.Signer{certSigner, signer}, nil
+}
+
+// agent to get signers from a new agent connection, which is kept open for signing during handshake
+func (a *auth) agent() ([]cryptoSsh.Signer, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.conn != nil {
+		_ = a.conn.Close()
+		a.conn = nil
+	}
+
+	conn, err := net.Dial("unix", os.Getenv(authSock))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to dial agent")
+	}
+
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "failed to get signers")
+	}
+
+	a.conn = conn
+
+	return signers, nil
+}
+
+func (a *auth) close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.conn != nil {
+		_ = a.conn.Close()
+		a.conn = nil
+	}
+}
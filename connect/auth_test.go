@@ -0,0 +1,199 @@
+package connect
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	cryptoSsh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+func initAuth(cfg *config.Ssh) (*auth, error) {
+	return newAuth(cfg, hclog.New(&hclog.LoggerOptions{
+		Name:  "auth",
+		Level: hclog.LevelFromString("INFO"),
+	}))
+}
+
+func initKey(t *testing.T, passphrase string) (string, ed25519.PrivateKey) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+
+	var block *pem.Block
+
+	if passphrase != "" {
+		block, _ = cryptoSsh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	} else {
+		block, _ = cryptoSsh.MarshalPrivateKey(key, "")
+	}
+
+	name := filepath.Join(t.TempDir(), "id_ed25519")
+	_ = os.WriteFile(name, pem.EncodeToMemory(block), 0o600)
+
+	return name, key
+}
+
+func initAuthServer(t *testing.T, key cryptoSsh.PublicKey) string {
+	addr, _ := initSshServer(t, &cryptoSsh.ServerConfig{
+		PublicKeyCallback: func(_ cryptoSsh.ConnMetadata, pub cryptoSsh.PublicKey) (*cryptoSsh.Permissions, error) {
+			if bytes.Equal(pub.Marshal(), key.Marshal()) {
+				return &cryptoSsh.Permissions{}, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}, nil)
+
+	return addr
+}
+
+func dialAuth(addr string, a *auth) error {
+	c, err := cryptoSsh.Dial("tcp", addr, &cryptoSsh.ClientConfig{
+		User:            "user",
+		Auth:            []cryptoSsh.AuthMethod{a.method()},
+		HostKeyCallback: cryptoSsh.InsecureIgnoreHostKey(),
+		Timeout:         time.Second,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Close()
+}
+
+func TestAuthKeyfile(t *testing.T) {
+	t.Setenv(authSock, "")
+
+	_, err := initAuth(&config.Ssh{})
+	assert.NotEqual(t, nil, err)
+
+	name, key := initKey(t, "pass")
+	pub, _ := cryptoSsh.NewPublicKey(key.Public())
+	addr := initAuthServer(t, pub)
+
+	_, err = initAuth(&config.Ssh{Keyfile: name})
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, strings.Contains(err.Error(), "keyfilePassword required"))
+
+	_, err = initAuth(&config.Ssh{Keyfile: name, KeyfilePassword: "invalid"})
+	assert.NotEqual(t, nil, err)
+
+	a, err := initAuth(&config.Ssh{Keyfile: name, KeyfilePassword: "pass"})
+	assert.Equal(t, nil, err)
+
+	err = dialAuth(addr, a)
+	assert.Equal(t, nil, err)
+
+	_, err = initAuth(&config.Ssh{AuthMethods: []string{authAgent}, Keyfile: name, KeyfilePassword: "pass"})
+	assert.NotEqual(t, nil, err)
+
+	_, err = initAuth(&config.Ssh{AuthMethods: []string{"invalid"}})
+	assert.NotEqual(t, nil, err)
+}
+
+func TestAuthAgent(t *testing.T) {
+	name, _ := initKey(t, "")
+	_, key := initKey(t, "")
+
+	keyring := agent.NewKeyring()
+	_ = keyring.Add(agent.AddedKey{PrivateKey: key})
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+
+	l, err := net.Listen("unix", sock)
+	assert.Equal(t, nil, err)
+
+	defer func() {
+		_ = l.Close()
+	}()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, c)
+				_ = c.Close()
+			}()
+		}
+	}()
+
+	t.Setenv(authSock, sock)
+
+	pub, _ := cryptoSsh.NewPublicKey(key.Public())
+	addr := initAuthServer(t, pub)
+
+	// Agent is tried after keyfile not authorized
+	a, err := initAuth(&config.Ssh{Keyfile: name})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{authKeyfile, authAgent}, a.methods)
+
+	err = dialAuth(addr, a)
+	assert.Equal(t, nil, err)
+
+	a.close()
+
+	a, _ = initAuth(&config.Ssh{AuthMethods: []string{authKeyfile}, Keyfile: name})
+
+	err = dialAuth(addr, a)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestAuthCertificate(t *testing.T) {
+	t.Setenv(authSock, "")
+
+	name, key := initKey(t, "")
+
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := cryptoSsh.NewSignerFromKey(caKey)
+
+	pub, _ := cryptoSsh.NewPublicKey(key.Public())
+
+	cert := &cryptoSsh.Certificate{
+		Key:             pub,
+		CertType:        cryptoSsh.UserCert,
+		KeyId:           "user",
+		ValidPrincipals: []string{"user"},
+		ValidBefore:     cryptoSsh.CertTimeInfinity,
+	}
+
+	_ = cert.SignCert(rand.Reader, ca)
+
+	certfile := filepath.Join(t.TempDir(), "id_ed25519-cert.pub")
+	_ = os.WriteFile(certfile, cryptoSsh.MarshalAuthorizedKey(cert), 0o600)
+
+	checker := &cryptoSsh.CertChecker{
+		IsUserAuthority: func(auth cryptoSsh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.PublicKey().Marshal())
+		},
+	}
+
+	addr, _ := initSshServer(t, &cryptoSsh.ServerConfig{PublicKeyCallback: checker.Authenticate}, nil)
+
+	a, err := initAuth(&config.Ssh{Keyfile: name})
+	assert.Equal(t, nil, err)
+
+	err = dialAuth(addr, a)
+	assert.NotEqual(t, nil, err)
+
+	a, err = initAuth(&config.Ssh{Certfile: certfile, Keyfile: name})
+	assert.Equal(t, nil, err)
+
+	err = dialAuth(addr, a)
+	assert.Equal(t, nil, err)
+
+	_, err = initAuth(&config.Ssh{Certfile: name, Keyfile: name})
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,210 @@
+package connect
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	cryptoSsh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+const (
+	knownHostsFile = "~/.ssh/known_hosts"
+	knownHostsMode = 0o600
+	knownHostsPerm = 0o700
+)
+
+var (
+	// Host key algorithms in order of preference, and those of keys in known_hosts are negotiated if found
+	hostKeyAlgorithms = []string{
+		cryptoSsh.KeyAlgoED25519,
+		cryptoSsh.KeyAlgoECDSA256,
+		cryptoSsh.KeyAlgoECDSA384,
+		cryptoSsh.KeyAlgoECDSA521,
+		cryptoSsh.KeyAlgoRSASHA512,
+		cryptoSsh.KeyAlgoRSASHA256,
+		cryptoSsh.KeyAlgoRSA,
+		cryptoSsh.KeyAlgoDSA,
+	}
+)
+
+// hostKey to verify host key by pinned fingerprints, or by known_hosts with trust on first use
+type hostKey struct {
+	cfg    *config.Ssh
+	logger hclog.Logger
+	mutex  sync.Mutex
+	path   string
+}
+
+func newHostKey(cfg *config.Ssh, logger hclog.Logger) (*hostKey, error) {
+	path := cfg.KnownHosts
+	if path == "" {
+		path = knownHostsFile
+	}
+
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get home")
+		}
+		path = filepath.Join(home, path[2:])
+	}
+
+	return &hostKey{
+		cfg:    cfg,
+		logger: logger,
+		path:   path,
+	}, nil
+}
+
+func (h *hostKey) callback(hostname string, remote net.Addr, key cryptoSsh.PublicKey) error {
+	fingerprint := cryptoSsh.FingerprintSHA256(key)
+
+	if len(h.cfg.Fingerprints) != 0 {
+		for _, item := range h.cfg.Fingerprints {
+			if item == fingerprint {
+				return nil
+			}
+		}
+		return errors.Errorf("host key mismatch for %s: %s %s is not pinned in fingerprints", hostname, key.Type(), fingerprint)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// Load file on each connection to see keys appended meanwhile
+	check, err := h.load()
+	if err != nil {
+		return errors.Wrap(err, "failed to load known hosts")
+	}
+
+	err = check(hostname, remote, key)
+	if err == nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+
+	if !errors.As(err, &keyErr) {
+		return errors.Wrapf(err, "failed to verify host key for %s", hostname)
+	}
+
+	if len(keyErr.Want) != 0 {
+		want := make([]string, len(keyErr.Want))
+		for i := range keyErr.Want {
+			want[i] = fmt.Sprintf("%s (%s:%d)", cryptoSsh.FingerprintSHA256(keyErr.Want[i].Key), keyErr.Want[i].Filename, keyErr.Want[i].Line)
+		}
+		return errors.Errorf("host key mismatch for %s: got %s %s, want %s, possible man-in-the-middle attack",
+			hostname, key.Type(), fingerprint, strings.Join(want, ", "))
+	}
+
+	if !h.cfg.TrustOnFirstUse {
+		return errors.Errorf("unknown host key for %s: %s %s is not found in %s", hostname, key.Type(), fingerprint, h.path)
+	}
+
+	if err := h.store(hostname, key); err != nil {
+		return errors.Wrap(err, "failed to store known hosts")
+	}
+
+	h.logger.Warn("hostkey: callback", "hostname", hostname, "type", key.Type(), "fingerprint", fingerprint, "trust", "first use")
+
+	return nil
+}
+
+// algorithms returns host key algorithms of keys stored for address in known_hosts, otherwise all algorithms.
+// Server offering more key types than stored, e.g., ECDSA besides ED25519, negotiates the stored one instead of mismatch.
+func (h *hostKey) algorithms(address string) []string {
+	if len(h.cfg.Fingerprints) != 0 {
+		return hostKeyAlgorithms
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	check, err := h.load()
+	if err != nil {
+		return hostKeyAlgorithms
+	}
+
+	// Random key never matches, and keys stored for address are wanted in error
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return hostKeyAlgorithms
+	}
+
+	key, err := cryptoSsh.NewPublicKey(pub)
+	if err != nil {
+		return hostKeyAlgorithms
+	}
+
+	var keyErr *knownhosts.KeyError
+
+	if err := check(address, &net.TCPAddr{IP: net.IPv4zero}, key); !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		return hostKeyAlgorithms
+	}
+
+	types := map[string]bool{}
+
+	for i := range keyErr.Want {
+		types[keyErr.Want[i].Key.Type()] = true
+	}
+
+	var buf []string
+
+	for _, item := range hostKeyAlgorithms {
+		// e.g., "rsa-sha2-512" signed by key of "ssh-rsa"
+		if types[item] || (strings.HasPrefix(item, "rsa-sha2-") && types[cryptoSsh.KeyAlgoRSA]) {
+			buf = append(buf, item)
+		}
+	}
+
+	if len(buf) == 0 {
+		return hostKeyAlgorithms
+	}
+
+	return buf
+}
+
+func (h *hostKey) load() (cryptoSsh.HostKeyCallback, error) {
+	if _, err := os.Stat(h.path); err != nil {
+		if os.IsNotExist(err) && h.cfg.TrustOnFirstUse {
+			return func(string, net.Addr, cryptoSsh.PublicKey) error {
+				return &knownhosts.KeyError{}
+			}, nil
+		}
+		return nil, err
+	}
+
+	return knownhosts.New(h.path)
+}
+
+func (h *hostKey) store(hostname string, key cryptoSsh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), knownHostsPerm); err != nil {
+		return errors.Wrap(err, "failed to make dir")
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, knownHostsMode)
+	if err != nil {
+		return errors.Wrap(err, "failed to open")
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err := f.WriteString(knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key) + "\n"); err != nil {
+		return errors.Wrap(err, "failed to write")
+	}
+
+	return f.Sync()
+}
@@ -0,0 +1,180 @@
+package connect
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	cryptoSsh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+func initHostKey(cfg *config.Ssh) *hostKey {
+	h, _ := newHostKey(cfg, hclog.New(&hclog.LoggerOptions{
+		Name:  "hostkey",
+		Level: hclog.LevelFromString("INFO"),
+	}))
+
+	return h
+}
+
+func initSshServer(t *testing.T, cfg *cryptoSsh.ServerConfig, handle func(cryptoSsh.NewChannel)) (string, cryptoSsh.PublicKey) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := cryptoSsh.NewSignerFromKey(key)
+
+	if cfg == nil {
+		cfg = &cryptoSsh.ServerConfig{NoClientAuth: true}
+	}
+
+	cfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn, chans, reqs, err := cryptoSsh.NewServerConn(c, cfg)
+				if err != nil {
+					_ = c.Close()
+					return
+				}
+				go cryptoSsh.DiscardRequests(reqs)
+				for ch := range chans {
+					if handle == nil {
+						_ = ch.Reject(cryptoSsh.Prohibited, "")
+						continue
+					}
+					go handle(ch)
+				}
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	return l.Addr().String(), signer.PublicKey()
+}
+
+func dialSsh(addr string, h *hostKey) error {
+	c, err := cryptoSsh.Dial("tcp", addr, &cryptoSsh.ClientConfig{
+		User:              "user",
+		HostKeyAlgorithms: h.algorithms(addr),
+		HostKeyCallback:   h.callback,
+		Timeout:           time.Second,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Close()
+}
+
+func TestHostKeyKnownHosts(t *testing.T) {
+	addr, _ := initSshServer(t, nil, nil)
+	name := filepath.Join(t.TempDir(), ".ssh", "known_hosts")
+
+	h := initHostKey(&config.Ssh{KnownHosts: name})
+
+	err := dialSsh(addr, h)
+	assert.NotEqual(t, nil, err)
+
+	h = initHostKey(&config.Ssh{KnownHosts: name, TrustOnFirstUse: true})
+
+	err = dialSsh(addr, h)
+	assert.Equal(t, nil, err)
+
+	buf, _ := os.ReadFile(name)
+	assert.Equal(t, 1, strings.Count(string(buf), "\n"))
+
+	// Key persisted on first use is verified without trust on first use
+	h = initHostKey(&config.Ssh{KnownHosts: name})
+
+	err = dialSsh(addr, h)
+	assert.Equal(t, nil, err)
+
+	buf, _ = os.ReadFile(name)
+	assert.Equal(t, 1, strings.Count(string(buf), "\n"))
+}
+
+func TestHostKeyMismatch(t *testing.T) {
+	addr, _ := initSshServer(t, nil, nil)
+	_, key := initSshServer(t, nil, nil)
+	name := filepath.Join(t.TempDir(), "known_hosts")
+
+	_ = os.WriteFile(name, []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, key)+"\n"), knownHostsMode)
+
+	h := initHostKey(&config.Ssh{KnownHosts: name, TrustOnFirstUse: true})
+
+	err := dialSsh(addr, h)
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, strings.Contains(err.Error(), "host key mismatch"))
+
+	buf, _ := os.ReadFile(name)
+	assert.Equal(t, 1, strings.Count(string(buf), "\n"))
+}
+
+func TestHostKeyFingerprints(t *testing.T) {
+	addr, key := initSshServer(t, nil, nil)
+	_, other := initSshServer(t, nil, nil)
+
+	h := initHostKey(&config.Ssh{Fingerprints: []string{cryptoSsh.FingerprintSHA256(other), cryptoSsh.FingerprintSHA256(key)}})
+
+	err := dialSsh(addr, h)
+	assert.Equal(t, nil, err)
+
+	h = initHostKey(&config.Ssh{Fingerprints: []string{cryptoSsh.FingerprintSHA256(other)}, TrustOnFirstUse: true})
+
+	err = dialSsh(addr, h)
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, strings.Contains(err.Error(), "host key mismatch"))
+}
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecdsaSigner, _ := cryptoSsh.NewSignerFromKey(ecdsaKey)
+
+	// Server offers ECDSA besides ED25519, and only ED25519 is stored in known_hosts
+	cfg := &cryptoSsh.ServerConfig{NoClientAuth: true}
+	cfg.AddHostKey(ecdsaSigner)
+
+	addr, key := initSshServer(t, cfg, nil)
+	name := filepath.Join(t.TempDir(), "known_hosts")
+
+	h := initHostKey(&config.Ssh{KnownHosts: name})
+	assert.Equal(t, hostKeyAlgorithms, h.algorithms(addr))
+
+	_ = os.WriteFile(name, []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, key)+"\n"), knownHostsMode)
+
+	assert.Equal(t, []string{cryptoSsh.KeyAlgoED25519}, h.algorithms(addr))
+
+	err := dialSsh(addr, h)
+	assert.Equal(t, nil, err)
+
+	// Server key of the other type is negotiated if stored instead
+	pub, _ := cryptoSsh.NewPublicKey(ecdsaKey.Public())
+	_ = os.WriteFile(name, []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, pub)+"\n"), knownHostsMode)
+
+	assert.Equal(t, []string{cryptoSsh.KeyAlgoECDSA256}, h.algorithms(addr))
+
+	err = dialSsh(addr, h)
+	assert.Equal(t, nil, err)
+}
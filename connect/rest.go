@@ -214,7 +214,7 @@ func (r *rest) Version(_ context.Context) (string, error) {
 	return buf, nil
 }
 
-func (r *rest) Vote(_ context.Context, change, revision int, label, message, vote string) error {
+func (r *rest) Vote(ctx context.Context, change, revision int, label, message, vote string) error {
 	url := r.url + CHANGES + strconv.Itoa(change) + REVISIONS + strconv.Itoa(revision) + REVIEW
 	if r.user != "" && r.pass != "" {
 		url = r.url + PREFIX + CHANGES + strconv.Itoa(change) + REVISIONS + strconv.Itoa(revision) + REVIEW
@@ -222,16 +222,20 @@ func (r *rest) Vote(_ context.Context, change, revision int, label, message, vot
 
 	buf := map[string]any{
 		"comments": nil,
-		"labels":   map[string]any{label: vote},
 		"message":  message,
 	}
 
+	// Post message only if no vote is set
+	if label != "" && vote != "" {
+		buf["labels"] = map[string]any{label: vote}
+	}
+
 	body, err := json.Marshal(buf)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal")
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
 	if err != nil {
 		return errors.Wrap(err, "failed to set request")
 	}
@@ -2,6 +2,9 @@ package connect
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/hashicorp/go-hclog"
@@ -63,3 +66,38 @@ func TestVersion(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.NotEqual(t, nil, buf)
 }
+
+func TestVote(t *testing.T) {
+	var buf map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != PREFIX+CHANGES+"1"+REVISIONS+"2"+REVIEW {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&buf)
+		_, _ = w.Write([]byte(")]}'\n{}"))
+	}))
+	defer server.Close()
+
+	r := initRest().(*rest)
+	r.pass = "pass"
+	r.url = server.URL
+	r.user = "user"
+
+	ctx := context.Background()
+
+	err := r.Vote(ctx, 1, 2, "Verified", "Build started", "0")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Build started", buf["message"])
+	assert.Equal(t, map[string]any{"Verified": "0"}, buf["labels"])
+
+	buf = nil
+
+	err = r.Vote(ctx, 1, 2, "Verified", "Build started", "")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, buf["labels"])
+
+	err = r.Vote(ctx, 3, 2, "Verified", "Build started", "0")
+	assert.NotEqual(t, nil, err)
+}
@@ -5,8 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
@@ -21,6 +20,9 @@ import (
 const (
 	num    = -1
 	prefix = "gerrit "
+
+	sshSessions = 4
+	sshTimeout  = 60 * time.Second
 )
 
 type Ssh interface {
@@ -28,6 +30,7 @@ type Ssh interface {
 	Deinit(context.Context) error
 	Run(context.Context, string) (string, error)
 	Start(context.Context, string, queue.Queue) error
+	Wait(context.Context) error
 	Reconnect(context.Context) error
 }
 
@@ -36,18 +39,38 @@ type SshConfig struct {
 	Logger hclog.Logger
 }
 
+// ssh to multiplex sessions on one client, with one session per command and a dedicated session for streaming
 type ssh struct {
+	auth         *auth
 	cfg          *SshConfig
 	client       *cryptoSsh.Client
 	clientConfig *cryptoSsh.ClientConfig
-	session      *cryptoSsh.Session
+	hostKey      *hostKey
+	mutex        sync.RWMutex
+	reconnect    sync.Mutex
+	sessions     chan struct{}
+	stream       *cryptoSsh.Session
+	streamDone   chan error
+	timeout      time.Duration
 }
 
 func SshNew(_ context.Context, cfg *SshConfig) Ssh {
+	sessions := cfg.Config.Spec.Connect.Ssh.Sessions
+	if sessions <= 0 {
+		sessions = sshSessions
+	}
+
+	timeout := time.Duration(cfg.Config.Spec.Connect.Ssh.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = sshTimeout
+	}
+
 	return &ssh{
 		cfg:          cfg,
 		client:       nil,
 		clientConfig: nil,
+		sessions:     make(chan struct{}, sessions),
+		timeout:      timeout,
 	}
 }
 
@@ -55,67 +78,42 @@ func DefaultSshConfig() *SshConfig {
 	return &SshConfig{}
 }
 
-func (s *ssh) Init(_ context.Context) error {
+func (s *ssh) Init(ctx context.Context) error {
 	s.cfg.Logger.Debug("ssh: Init")
 
 	var err error
 
-	key, err := os.ReadFile(s.cfg.Config.Spec.Connect.Ssh.Keyfile)
+	s.auth, err = newAuth(&s.cfg.Config.Spec.Connect.Ssh, s.cfg.Logger)
 	if err != nil {
-		return errors.Wrap(err, "failed to read file")
+		return errors.Wrap(err, "failed to init auth")
 	}
 
-	signer, err := cryptoSsh.ParsePrivateKey(key)
+	s.hostKey, err = newHostKey(&s.cfg.Config.Spec.Connect.Ssh, s.cfg.Logger)
 	if err != nil {
-		return errors.Wrap(err, "failed to parse key")
-	}
-
-	hostKeyCallback := func(name string, addr net.Addr, key cryptoSsh.PublicKey) error {
-		return nil
+		return errors.Wrap(err, "failed to init host key")
 	}
 
 	s.clientConfig = &cryptoSsh.ClientConfig{
 		User: s.cfg.Config.Spec.Connect.Ssh.Username,
 		Auth: []cryptoSsh.AuthMethod{
-			cryptoSsh.PublicKeys(signer),
-		},
-		HostKeyAlgorithms: []string{
-			cryptoSsh.KeyAlgoDSA,
-			cryptoSsh.KeyAlgoECDSA256,
-			cryptoSsh.KeyAlgoECDSA384,
-			cryptoSsh.KeyAlgoECDSA521,
-			cryptoSsh.KeyAlgoED25519,
-			cryptoSsh.KeyAlgoRSA,
+			s.auth.method(),
 		},
 		Timeout:         time.Duration(s.cfg.Config.Spec.Watchdog.TimeoutSeconds) * time.Second,
-		HostKeyCallback: hostKeyCallback,
-	}
-
-	host := s.cfg.Config.Spec.Connect.Hostname
-	port := s.cfg.Config.Spec.Connect.Ssh.Port
-
-	s.client, err = cryptoSsh.Dial("tcp", fmt.Sprintf("%s:%d", host, port), s.clientConfig)
-	if err != nil {
-		s.client = nil
-		return errors.Wrap(err, "failed to connect server")
-	}
-
-	s.session, err = s.client.NewSession()
-	if err != nil {
-		_ = s.client.Close()
-		s.client = nil
-		return errors.Wrap(err, "failed to create session")
+		HostKeyCallback: s.hostKey.callback,
 	}
 
-	return nil
+	return s.dial(ctx)
 }
 
 func (s *ssh) Deinit(_ context.Context) error {
 	s.cfg.Logger.Debug("ssh: Deinit")
 
-	if s.session != nil {
-		_ = s.session.Close()
-		s.session = nil
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stream != nil {
+		_ = s.stream.Close()
+		s.stream = nil
 	}
 
 	if s.client != nil {
@@ -123,22 +121,70 @@ func (s *ssh) Deinit(_ context.Context) error {
 		s.client = nil
 	}
 
+	if s.auth != nil {
+		s.auth.close()
+	}
+
 	return nil
 }
 
-func (s *ssh) Run(_ context.Context, cmd string) (string, error) {
-	if s.client == nil {
-		return "", errors.New("invalid client")
+// Run to run command in a new session, bounded by the number of sessions and timeout
+func (s *ssh) Run(ctx context.Context, cmd string) (string, error) {
+	select {
+	case s.sessions <- struct{}{}:
+	case <-ctx.Done():
+		return "", errors.Wrap(ctx.Err(), "failed to wait session")
 	}
 
-	out, err := s.session.CombinedOutput(prefix + cmd)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to run session")
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	type result struct {
+		err error
+		out []byte
 	}
 
-	return string(out), nil
+	done := make(chan result, 1)
+	closed := make(chan struct{})
+
+	// Session is created in timeout too since it blocks on a stalled connection, and slot is released once session ends
+	go func() {
+		defer func() {
+			<-s.sessions
+		}()
+		session, err := s.session()
+		if err != nil {
+			done <- result{err, nil}
+			return
+		}
+		defer func() {
+			_ = session.Close()
+		}()
+		go func() {
+			// Closing session makes the command exit on server
+			select {
+			case <-ctx.Done():
+				_ = session.Close()
+			case <-closed:
+			}
+		}()
+		out, err := session.CombinedOutput(prefix + cmd)
+		close(closed)
+		done <- result{errors.Wrap(err, "failed to run session"), out}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return "", r.err
+		}
+		return string(r.out), nil
+	case <-ctx.Done():
+		return "", errors.Wrap(ctx.Err(), "failed to run session")
+	}
 }
 
+// Start to run command in the streaming session, and the previous streaming session is closed
 func (s *ssh) Start(ctx context.Context, cmd string, _queue queue.Queue) error {
 	helper := func(r io.Reader) error {
 		scan := bufio.NewScanner(r)
@@ -149,20 +195,33 @@ func (s *ssh) Start(ctx context.Context, cmd string, _queue queue.Queue) error {
 		return scan.Err()
 	}
 
-	if s.client == nil {
-		return errors.New("invalid client")
+	session, err := s.session()
+	if err != nil {
+		return err
 	}
 
-	stderr, err := s.session.StderrPipe()
+	stderr, err := session.StderrPipe()
 	if err != nil {
+		_ = session.Close()
 		return errors.Wrap(err, "failed to pipe stderr")
 	}
 
-	stdout, err := s.session.StdoutPipe()
+	stdout, err := session.StdoutPipe()
 	if err != nil {
+		_ = session.Close()
 		return errors.Wrap(err, "failed to pipe stdout")
 	}
 
+	done := make(chan error, 1)
+
+	s.mutex.Lock()
+	if s.stream != nil {
+		_ = s.stream.Close()
+	}
+	s.stream = session
+	s.streamDone = done
+	s.mutex.Unlock()
+
 	g, _ := errgroup.WithContext(ctx)
 	g.SetLimit(num)
 
@@ -174,38 +233,91 @@ func (s *ssh) Start(ctx context.Context, cmd string, _queue queue.Queue) error {
 		return helper(stdout)
 	})
 
-	if err := s.session.Start(prefix + cmd); err != nil {
+	if err := session.Start(prefix + cmd); err != nil {
+		_ = session.Close()
 		return errors.Wrap(err, "failed to start session")
 	}
 
 	g.Go(func() error {
-		_ = s.session.Wait()
+		done <- session.Wait()
 		return nil
 	})
 
 	return nil
 }
 
+// Wait to wait for the streaming session to end, e.g., closed by server or connection lost
+func (s *ssh) Wait(ctx context.Context) error {
+	s.mutex.RLock()
+	done := s.streamDone
+	s.mutex.RUnlock()
+
+	if done == nil {
+		return errors.New("invalid stream")
+	}
+
+	select {
+	case err := <-done:
+		if err == nil {
+			return errors.New("stream ended")
+		}
+		return errors.Wrap(err, "stream ended")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reconnect to replace client, and serialize callers such as watchdog and stream supervisor
 func (s *ssh) Reconnect(ctx context.Context) error {
-	var err error
+	s.reconnect.Lock()
+	defer s.reconnect.Unlock()
 
 	_ = s.Deinit(ctx)
 
+	return s.dial(ctx)
+}
+
+func (s *ssh) dial(_ context.Context) error {
 	host := s.cfg.Config.Spec.Connect.Hostname
 	port := s.cfg.Config.Spec.Connect.Ssh.Port
 
-	s.client, err = cryptoSsh.Dial("tcp", fmt.Sprintf("%s:%d", host, port), s.clientConfig)
+	addr := fmt.Sprintf("%s:%d", host, port)
+
+	// Host key algorithms are chosen on each dial since known_hosts may be updated meanwhile
+	cfg := *s.clientConfig
+	cfg.HostKeyAlgorithms = s.hostKey.algorithms(addr)
+
+	client, err := cryptoSsh.Dial("tcp", addr, &cfg)
 	if err != nil {
-		s.client = nil
 		return errors.Wrap(err, "failed to connect server")
 	}
 
-	s.session, err = s.client.NewSession()
-	if err != nil {
-		_ = s.client.Close()
-		s.client = nil
-		return errors.Wrap(err, "failed to create session")
+	s.mutex.Lock()
+	prev := s.client
+	s.client = client
+	s.mutex.Unlock()
+
+	if prev != nil {
+		_ = prev.Close()
 	}
 
 	return nil
 }
+
+func (s *ssh) session() (*cryptoSsh.Session, error) {
+	s.mutex.RLock()
+	client := s.client
+	s.mutex.RUnlock()
+
+	// Client closed meanwhile fails to create session instead of blocking reconnection
+	if client == nil {
+		return nil, errors.New("invalid client")
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create session")
+	}
+
+	return session, nil
+}
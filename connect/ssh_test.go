@@ -1,9 +1,194 @@
 package connect
 
 import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	cryptoSsh "golang.org/x/crypto/ssh"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/queue"
+)
+
+const (
+	testEvent   = `{"type":"ref-updated"}`
+	testSleep   = 10 * time.Second
+	testVersion = "gerrit version 3.9.1\n"
 )
 
+// handleExec to run fake gerrit commands in session
+func handleExec(ch cryptoSsh.NewChannel) {
+	c, reqs, err := ch.Accept()
+	if err != nil {
+		return
+	}
+
+	for req := range reqs {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var payload struct {
+			Command string
+		}
+		_ = cryptoSsh.Unmarshal(req.Payload, &payload)
+		_ = req.Reply(true, nil)
+		go func() {
+			switch payload.Command {
+			case prefix + "version":
+				_, _ = io.WriteString(c, testVersion)
+			case prefix + "stream-events":
+				_, _ = io.WriteString(c, testEvent+"\n"+testEvent+"\n")
+				time.Sleep(testSleep)
+			case prefix + "sleep":
+				time.Sleep(testSleep)
+			}
+			_, _ = c.SendRequest("exit-status", false, cryptoSsh.Marshal(struct{ Status uint32 }{0}))
+			_ = c.Close()
+		}()
+	}
+}
+
+func initSsh(t *testing.T) *ssh {
+	addr, _ := initSshServer(t, &cryptoSsh.ServerConfig{NoClientAuth: true}, handleExec)
+	host, port, _ := net.SplitHostPort(addr)
+	name, _ := initKey(t, "")
+
+	t.Setenv(authSock, "")
+
+	cfg := DefaultSshConfig()
+	cfg.Config = config.Config{}
+	cfg.Config.Spec.Connect.Hostname = host
+	cfg.Config.Spec.Connect.Ssh = config.Ssh{
+		Keyfile:         name,
+		KnownHosts:      filepath.Join(t.TempDir(), "known_hosts"),
+		Sessions:        2,
+		TimeoutSeconds:  1,
+		TrustOnFirstUse: true,
+		Username:        "user",
+	}
+	cfg.Config.Spec.Connect.Ssh.Port, _ = strconv.Atoi(port)
+
+	cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "ssh",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	return SshNew(context.Background(), cfg).(*ssh)
+}
+
 func TestSsh(t *testing.T) {
-	// PASS
+	s := initSsh(t)
+	ctx := context.Background()
+
+	_, err := s.Run(ctx, "version")
+	assert.NotEqual(t, nil, err)
+
+	err = s.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	defer func(s *ssh, ctx context.Context) {
+		_ = s.Deinit(ctx)
+	}(s, ctx)
+
+	qc := queue.DefaultConfig()
+	qc.Logger = s.cfg.Logger
+
+	q := queue.New(ctx, qc)
+	_ = q.Init(ctx)
+
+	defer func(q queue.Queue, ctx context.Context) {
+		_ = q.Close(ctx)
+	}(q, ctx)
+
+	err = s.Start(ctx, "stream-events", q)
+	assert.Equal(t, nil, err)
+
+	r, _ := q.Get(ctx)
+	assert.Equal(t, testEvent, <-r)
+
+	// Commands run concurrently besides streaming
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := s.Run(ctx, "version")
+			assert.Equal(t, nil, err)
+			assert.Equal(t, testVersion, out)
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, testEvent, <-r)
+}
+
+func TestSshTimeout(t *testing.T) {
+	s := initSsh(t)
+	ctx := context.Background()
+
+	_ = s.Init(ctx)
+
+	defer func(s *ssh, ctx context.Context) {
+		_ = s.Deinit(ctx)
+	}(s, ctx)
+
+	err := s.Wait(ctx)
+	assert.NotEqual(t, nil, err)
+
+	now := time.Now()
+
+	_, err = s.Run(ctx, "sleep")
+	assert.NotEqual(t, nil, err)
+	assert.Less(t, time.Since(now), 3*time.Second)
+
+	out, err := s.Run(ctx, "version")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, testVersion, out)
+
+	err = s.Reconnect(ctx)
+	assert.Equal(t, nil, err)
+
+	out, err = s.Run(ctx, "version")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, testVersion, out)
+}
+
+func TestSshReconnect(t *testing.T) {
+	s := initSsh(t)
+	ctx := context.Background()
+
+	_ = s.Init(ctx)
+
+	defer func(s *ssh, ctx context.Context) {
+		_ = s.Deinit(ctx)
+	}(s, ctx)
+
+	// Reconnection by watchdog and stream supervisor at the same time
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Reconnect(ctx)
+			assert.Equal(t, nil, err)
+		}()
+	}
+
+	wg.Wait()
+
+	out, err := s.Run(ctx, "version")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, testVersion, out)
 }
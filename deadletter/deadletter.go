@@ -0,0 +1,184 @@
+package deadletter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+const (
+	drainSuffix = ".drain"
+	fileMode    = 0o600
+	lineBuffer  = 10 * 1024 * 1024
+)
+
+type DeadLetter interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Put(context.Context, *Letter) error
+	Drain(context.Context, func(*Letter) error) (int, int, error)
+}
+
+type Config struct {
+	Config config.Config
+	Logger hclog.Logger
+}
+
+// Letter to store one event failed permanently, event is the raw line of stream events
+type Letter struct {
+	Attempts int       `json:"attempts"`
+	Connect  string    `json:"connect"`
+	Error    string    `json:"error"`
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+}
+
+type deadLetter struct {
+	cfg   *Config
+	mutex sync.Mutex
+	path  string
+}
+
+func New(_ context.Context, cfg *Config) DeadLetter {
+	return &deadLetter{
+		cfg:  cfg,
+		path: cfg.Config.Spec.DeadLetter.Path,
+	}
+}
+
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+func (d *deadLetter) Init(_ context.Context) error {
+	d.cfg.Logger.Debug("deadletter: Init")
+
+	return nil
+}
+
+func (d *deadLetter) Deinit(_ context.Context) error {
+	d.cfg.Logger.Debug("deadletter: Deinit")
+
+	return nil
+}
+
+// Put to append letter to file, and letter is logged only if path is empty
+func (d *deadLetter) Put(_ context.Context, letter *Letter) error {
+	d.cfg.Logger.Error("deadletter: Put", "connect", letter.Connect, "attempts", letter.Attempts, "error", letter.Error)
+
+	if d.path == "" {
+		return nil
+	}
+
+	buf, err := json.Marshal(letter)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal")
+	}
+
+	return d.append([][]byte{buf})
+}
+
+// Drain to move letters out of file and run helper on each one, and return the numbers of letters done and all.
+// Letters failed in helper or invalid are put back, and file moved out is kept until drained for recovery from crash.
+// Letters put by running trigger meanwhile are kept in file.
+func (d *deadLetter) Drain(_ context.Context, helper func(*Letter) error) (done, total int, err error) {
+	if d.path == "" {
+		return 0, 0, errors.New("invalid path")
+	}
+
+	name := d.path + drainSuffix
+
+	// File left by the last drain crashed is drained first
+	if _, err := os.Stat(name); err != nil {
+		if !os.IsNotExist(err) {
+			return 0, 0, errors.Wrap(err, "failed to stat")
+		}
+		d.mutex.Lock()
+		err = os.Rename(d.path, name)
+		d.mutex.Unlock()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return 0, 0, nil
+			}
+			return 0, 0, errors.Wrap(err, "failed to rename")
+		}
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to read")
+	}
+
+	var failed [][]byte
+
+	scan := bufio.NewScanner(bytes.NewReader(b))
+	scan.Buffer(make([]byte, 0, lineBuffer), lineBuffer)
+
+	for scan.Scan() {
+		line := scan.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		total++
+		var l Letter
+		if err := json.Unmarshal(line, &l); err != nil {
+			d.cfg.Logger.Warn("deadletter: Drain", "error", err.Error())
+			failed = append(failed, bytes.Clone(line))
+			continue
+		}
+		if err := helper(&l); err != nil {
+			l.Error = err.Error()
+			buf, _ := json.Marshal(&l)
+			failed = append(failed, buf)
+			continue
+		}
+		done++
+	}
+
+	if err := scan.Err(); err != nil {
+		return done, total, errors.Wrap(err, "failed to scan "+name)
+	}
+
+	if err := d.append(failed); err != nil {
+		return done, total, errors.Wrap(err, "failed to put back")
+	}
+
+	if err := os.Remove(name); err != nil {
+		return done, total, errors.Wrap(err, "failed to remove")
+	}
+
+	return done, total, nil
+}
+
+func (d *deadLetter) append(lines [][]byte) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, fileMode)
+	if err != nil {
+		return errors.Wrap(err, "failed to open")
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err := f.Write(append(bytes.Join(lines, []byte("\n")), '\n')); err != nil {
+		return errors.Wrap(err, "failed to write")
+	}
+
+	return nil
+}
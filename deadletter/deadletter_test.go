@@ -0,0 +1,112 @@
+package deadletter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+func initDeadLetter(path string) *deadLetter {
+	d := &deadLetter{
+		cfg:  DefaultConfig(),
+		path: path,
+	}
+
+	d.cfg.Config = config.Config{}
+
+	d.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "deadletter",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	return d
+}
+
+func TestPut(t *testing.T) {
+	d := initDeadLetter("")
+	ctx := context.Background()
+
+	err := d.Put(ctx, &Letter{Event: "event"})
+	assert.Equal(t, nil, err)
+
+	_, _, err = d.Drain(ctx, nil)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestDrain(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "deadletter.jsonl")
+
+	d := initDeadLetter(name)
+	ctx := context.Background()
+
+	var buf []Letter
+
+	helper := func(l *Letter) error {
+		buf = append(buf, *l)
+		return nil
+	}
+
+	done, total, err := d.Drain(ctx, helper)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, done)
+	assert.Equal(t, 0, total)
+
+	_ = d.Put(ctx, &Letter{Attempts: 1, Connect: "gerrit", Error: "invalid", Event: `{"type":"patchset-created"}`})
+	_ = d.Put(ctx, &Letter{Attempts: 2, Connect: "gerrit", Error: "invalid", Event: `{"type":"comment-added"}`})
+
+	done, total, err = d.Drain(ctx, helper)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, done)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, `{"type":"patchset-created"}`, buf[0].Event)
+	assert.Equal(t, 2, buf[1].Attempts)
+
+	_, err = os.Stat(name)
+	assert.Equal(t, true, os.IsNotExist(err))
+
+	_, err = os.Stat(name + drainSuffix)
+	assert.Equal(t, true, os.IsNotExist(err))
+}
+
+func TestDrainFailed(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "deadletter.jsonl")
+
+	d := initDeadLetter(name)
+	ctx := context.Background()
+
+	// File left by crashed drain is kept, and invalid line is put back
+	_ = os.WriteFile(name+drainSuffix, []byte(`{"connect":"gerrit","event":"left"}`+"\ninvalid\n"), fileMode)
+	_ = d.Put(ctx, &Letter{Connect: "gerrit", Event: "new"})
+
+	var buf []string
+
+	done, total, err := d.Drain(ctx, func(l *Letter) error {
+		buf = append(buf, l.Event)
+		return errors.New("failed")
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, done)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []string{"left"}, buf)
+
+	buf = nil
+
+	done, total, err = d.Drain(ctx, func(l *Letter) error {
+		buf = append(buf, l.Event+" "+l.Error)
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, done)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"new ", "left failed"}, buf)
+
+	b, _ := os.ReadFile(name)
+	assert.Equal(t, "invalid\n", string(b))
+}
@@ -0,0 +1,194 @@
+package dispatch
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+const (
+	typeExec    = "exec"
+	typeJenkins = "jenkins"
+	typeLog     = "log"
+	typeWebhook = "webhook"
+)
+
+const (
+	StatusAborted   = "aborted"
+	StatusFailed    = "failed"
+	StatusQueued    = "queued"
+	StatusStarted   = "started"
+	StatusSucceeded = "succeeded"
+	StatusUnstable  = "unstable"
+)
+
+type Dispatch interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Run(context.Context, *Request) error
+	Cancel(context.Context, *Request) error
+}
+
+type Dispatcher interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Dispatch(context.Context, *Request) error
+	Cancel(context.Context, *Request) error
+}
+
+type Config struct {
+	Config config.Config
+	Logger hclog.Logger
+	Result chan *Result
+}
+
+// Request to store matched event and parameters for dispatchers
+type Request struct {
+	Cancel   bool // Cancel builds superseded by event instead of dispatching
+	Connect  string
+	Dispatch string
+	Event    *events.Event
+	Group    string
+	Id       string
+	Job      string
+	Jobs     []string // Jobs matched by event in group
+	Params   map[string]string
+}
+
+// Result to store build status reported by dispatchers
+type Result struct {
+	Dispatch string
+	Request  *Request
+	Status   string
+	Url      string
+}
+
+type dispatch struct {
+	cfg         *Config
+	dispatchers map[string]Dispatcher
+	names       []string
+}
+
+var (
+	registry = map[string]func(*Config, *config.Dispatch) Dispatcher{
+		typeExec:    newExec,
+		typeJenkins: newJenkins,
+		typeLog:     newLog,
+		typeWebhook: newWebhook,
+	}
+)
+
+func New(_ context.Context, cfg *Config) Dispatch {
+	return &dispatch{
+		cfg:         cfg,
+		dispatchers: map[string]Dispatcher{},
+		names:       []string{},
+	}
+}
+
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+func (d *dispatch) Init(ctx context.Context) error {
+	d.cfg.Logger.Debug("dispatch: Init")
+
+	buf := d.cfg.Config.Spec.Dispatch
+	if len(buf) == 0 {
+		buf = []config.Dispatch{{Name: typeLog, Type: typeLog}}
+	}
+
+	for i := range buf {
+		if _, ok := d.dispatchers[buf[i].Name]; ok {
+			return errors.New("duplicate name " + buf[i].Name)
+		}
+		n, ok := registry[strings.ToLower(buf[i].Type)]
+		if !ok {
+			return errors.New("invalid type " + buf[i].Type)
+		}
+		p := n(d.cfg, &buf[i])
+		if err := p.Init(ctx); err != nil {
+			return errors.Wrap(err, "failed to init "+buf[i].Name)
+		}
+		d.dispatchers[buf[i].Name] = p
+		d.names = append(d.names, buf[i].Name)
+	}
+
+	for _, item := range d.cfg.Config.Spec.Trigger.Jobs {
+		if _, ok := d.dispatchers[item.Dispatch]; item.Dispatch != "" && !ok {
+			return errors.New("invalid dispatch " + item.Dispatch + " in job " + item.Name)
+		}
+	}
+
+	return nil
+}
+
+func (d *dispatch) Deinit(ctx context.Context) error {
+	d.cfg.Logger.Debug("dispatch: Deinit")
+
+	for _, item := range d.names {
+		_ = d.dispatchers[item].Deinit(ctx)
+	}
+
+	return nil
+}
+
+func (d *dispatch) Run(ctx context.Context, req *Request) error {
+	if req.Dispatch != "" {
+		p, ok := d.dispatchers[req.Dispatch]
+		if !ok {
+			return errors.New("invalid dispatch " + req.Dispatch)
+		}
+		if err := p.Dispatch(ctx, req); err != nil {
+			return errors.Wrap(err, "failed to dispatch "+req.Dispatch)
+		}
+		return nil
+	}
+
+	for _, item := range d.names {
+		if err := d.dispatchers[item].Dispatch(ctx, req); err != nil {
+			return errors.Wrap(err, "failed to dispatch "+item)
+		}
+	}
+
+	return nil
+}
+
+func (d *dispatch) Cancel(ctx context.Context, req *Request) error {
+	if req.Dispatch != "" {
+		p, ok := d.dispatchers[req.Dispatch]
+		if !ok {
+			return errors.New("invalid dispatch " + req.Dispatch)
+		}
+		if err := p.Cancel(ctx, req); err != nil {
+			return errors.Wrap(err, "failed to cancel "+req.Dispatch)
+		}
+		return nil
+	}
+
+	for _, item := range d.names {
+		if err := d.dispatchers[item].Cancel(ctx, req); err != nil {
+			return errors.Wrap(err, "failed to cancel "+item)
+		}
+	}
+
+	return nil
+}
+
+func notify(cfg *Config, name string, req *Request, status, url string) {
+	if cfg.Result == nil {
+		return
+	}
+
+	cfg.Result <- &Result{
+		Dispatch: name,
+		Request:  req,
+		Status:   status,
+		Url:      url,
+	}
+}
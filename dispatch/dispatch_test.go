@@ -0,0 +1,106 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+func initDispatch() dispatch {
+	d := dispatch{
+		cfg:         DefaultConfig(),
+		dispatchers: map[string]Dispatcher{},
+		names:       []string{},
+	}
+
+	d.cfg.Config = config.Config{}
+
+	d.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "dispatch",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	return d
+}
+
+func TestInit(t *testing.T) {
+	d := initDispatch()
+	ctx := context.Background()
+
+	err := d.Init(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{typeLog}, d.names)
+
+	d = initDispatch()
+	d.cfg.Config.Spec.Dispatch = []config.Dispatch{{Name: "invalid", Type: "invalid"}}
+
+	err = d.Init(ctx)
+	assert.NotEqual(t, nil, err)
+
+	d = initDispatch()
+	d.cfg.Config.Spec.Dispatch = []config.Dispatch{{Name: "log", Type: "log"}, {Name: "log", Type: "log"}}
+
+	err = d.Init(ctx)
+	assert.NotEqual(t, nil, err)
+
+	d = initDispatch()
+	d.cfg.Config.Spec.Trigger.Jobs = []config.Job{{Dispatch: "invalid", Name: "job"}}
+
+	err = d.Init(ctx)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestRun(t *testing.T) {
+	d := initDispatch()
+	ctx := context.Background()
+
+	_ = d.Init(ctx)
+
+	defer func(d *dispatch, ctx context.Context) {
+		_ = d.Deinit(ctx)
+	}(&d, ctx)
+
+	req := Request{
+		Event:  &events.Event{Type: events.EventsPatchsetCreated},
+		Params: map[string]string{"GERRIT_EVENT_TYPE": events.EventsPatchsetCreated},
+	}
+
+	err := d.Run(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	req.Dispatch = typeLog
+
+	err = d.Run(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	req.Dispatch = "invalid"
+
+	err = d.Run(ctx, &req)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestCancel(t *testing.T) {
+	d := initDispatch()
+	ctx := context.Background()
+
+	_ = d.Init(ctx)
+
+	defer func(d *dispatch, ctx context.Context) {
+		_ = d.Deinit(ctx)
+	}(&d, ctx)
+
+	req := Request{Id: "id"}
+
+	err := d.Cancel(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	req.Dispatch = "invalid"
+
+	err = d.Cancel(ctx, &req)
+	assert.NotEqual(t, nil, err)
+}
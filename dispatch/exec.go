@@ -0,0 +1,182 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+const (
+	execConcurrency = 1
+)
+
+type execDispatcher struct {
+	cfg     *Config
+	exec    config.Exec
+	name    string
+	cancel  context.CancelFunc
+	cancels sync.Map
+	ctx     context.Context
+	sem     chan struct{}
+	wg      sync.WaitGroup
+}
+
+type execResult struct {
+	code   int
+	stderr string
+	stdout string
+}
+
+func newExec(cfg *Config, d *config.Dispatch) Dispatcher {
+	return &execDispatcher{
+		cfg:  cfg,
+		exec: d.Exec,
+		name: d.Name,
+	}
+}
+
+func (e *execDispatcher) Init(_ context.Context) error {
+	e.cfg.Logger.Debug("exec: Init")
+
+	if len(e.exec.Command) == 0 || e.exec.Command[0] == "" {
+		return errors.New("invalid command")
+	}
+
+	n := e.exec.Concurrency
+	if n <= 0 {
+		n = execConcurrency
+	}
+
+	e.sem = make(chan struct{}, n)
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+
+	return nil
+}
+
+func (e *execDispatcher) Deinit(_ context.Context) error {
+	e.cfg.Logger.Debug("exec: Deinit")
+
+	if e.cancel != nil {
+		e.cancel()
+	}
+
+	e.wg.Wait()
+
+	return nil
+}
+
+// Dispatch to queue request in background, and run it once one of concurrent slots is free
+func (e *execDispatcher) Dispatch(_ context.Context, req *Request) error {
+	ctx, cancel := context.WithCancel(e.ctx)
+
+	if req.Id != "" {
+		e.cancels.Store(req.Id, cancel)
+	}
+
+	e.wg.Add(1)
+
+	go func() {
+		defer func() {
+			if req.Id != "" {
+				e.cancels.Delete(req.Id)
+			}
+			cancel()
+			e.wg.Done()
+		}()
+		select {
+		case e.sem <- struct{}{}:
+		case <-ctx.Done():
+			notify(e.cfg, e.name, req, StatusAborted, "")
+			return
+		}
+		defer func() {
+			<-e.sem
+		}()
+		notify(e.cfg, e.name, req, StatusStarted, "")
+		r, err := e.run(ctx, req)
+		if err != nil {
+			e.cfg.Logger.Error("exec: Dispatch", "name", e.name, "code", r.code, "stdout", r.stdout, "stderr", r.stderr,
+				"error", err.Error())
+			if ctx.Err() != nil {
+				notify(e.cfg, e.name, req, StatusAborted, "")
+			} else {
+				notify(e.cfg, e.name, req, StatusFailed, "")
+			}
+			return
+		}
+		e.cfg.Logger.Info("exec: Dispatch", "name", e.name, "code", r.code, "stdout", r.stdout, "stderr", r.stderr)
+		notify(e.cfg, e.name, req, StatusSucceeded, "")
+	}()
+
+	return nil
+}
+
+func (e *execDispatcher) Cancel(_ context.Context, req *Request) error {
+	cancel, ok := e.cancels.Load(req.Id)
+	if !ok {
+		return nil
+	}
+
+	e.cfg.Logger.Info("exec: Cancel", "name", e.name, "job", req.Job, "id", req.Id)
+
+	cancel.(context.CancelFunc)()
+
+	return nil
+}
+
+func (e *execDispatcher) run(ctx context.Context, req *Request) (execResult, error) {
+	var stderr, stdout bytes.Buffer
+
+	if e.exec.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(e.exec.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	// nolint:gosec
+	cmd := exec.CommandContext(ctx, e.exec.Command[0], e.exec.Command[1:]...)
+	cmd.Dir = e.exec.Dir
+	cmd.Env = e.environ(req.Params)
+	cmd.Stderr = &stderr
+	cmd.Stdout = &stdout
+
+	err := cmd.Run()
+
+	r := execResult{
+		code:   cmd.ProcessState.ExitCode(),
+		stderr: stderr.String(),
+		stdout: stdout.String(),
+	}
+
+	if err != nil {
+		return r, errors.Wrap(err, "failed to run command")
+	}
+
+	return r, nil
+}
+
+func (e *execDispatcher) environ(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+
+	for key := range data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	env := os.Environ()
+
+	for _, key := range keys {
+		env = append(env, key+"="+data[key])
+	}
+
+	return env
+}
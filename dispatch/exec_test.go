@@ -0,0 +1,173 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/params"
+)
+
+func initExec(cmd []string) execDispatcher {
+	d := initDispatch()
+
+	return execDispatcher{
+		cfg: d.cfg,
+		exec: config.Exec{
+			Command:        cmd,
+			Concurrency:    2,
+			TimeoutSeconds: 5,
+		},
+		name: typeExec,
+	}
+}
+
+func TestExecInit(t *testing.T) {
+	e := initExec(nil)
+	ctx := context.Background()
+
+	err := e.Init(ctx)
+	assert.NotEqual(t, nil, err)
+
+	e = initExec([]string{"true"})
+
+	err = e.Init(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, cap(e.sem))
+
+	_ = e.Deinit(ctx)
+}
+
+func TestExecRun(t *testing.T) {
+	e := initExec([]string{"sh", "-c", "echo $" + params.ParamsGerritBranch + "; exit 3"})
+	ctx := context.Background()
+
+	_ = e.Init(ctx)
+
+	defer func(e *execDispatcher, ctx context.Context) {
+		_ = e.Deinit(ctx)
+	}(&e, ctx)
+
+	req := Request{
+		Params: map[string]string{params.ParamsGerritBranch: "main"},
+	}
+
+	r, err := e.run(ctx, &req)
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 3, r.code)
+	assert.Equal(t, "main\n", r.stdout)
+
+	e.exec.Command = []string{"true"}
+
+	r, err = e.run(ctx, &req)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, r.code)
+
+	err = e.Dispatch(ctx, &req)
+	assert.Equal(t, nil, err)
+}
+
+func TestExecDispatch(t *testing.T) {
+	e := initExec([]string{"true"})
+	ctx := context.Background()
+
+	e.cfg.Result = make(chan *Result, 4)
+
+	_ = e.Init(ctx)
+
+	req := Request{Params: map[string]string{}}
+
+	err := e.Dispatch(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	r := <-e.cfg.Result
+	assert.Equal(t, StatusStarted, r.Status)
+	assert.Equal(t, &req, r.Request)
+
+	r = <-e.cfg.Result
+	assert.Equal(t, StatusSucceeded, r.Status)
+
+	_ = e.Deinit(ctx)
+
+	e = initExec([]string{"false"})
+	e.cfg.Result = make(chan *Result, 4)
+
+	_ = e.Init(ctx)
+
+	err = e.Dispatch(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	<-e.cfg.Result
+	r = <-e.cfg.Result
+	assert.Equal(t, StatusFailed, r.Status)
+
+	_ = e.Deinit(ctx)
+}
+
+func TestExecCancel(t *testing.T) {
+	e := initExec([]string{"sleep", "5"})
+	ctx := context.Background()
+
+	e.cfg.Result = make(chan *Result, 4)
+
+	_ = e.Init(ctx)
+
+	req := Request{Id: "id", Params: map[string]string{}}
+
+	err := e.Dispatch(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	r := <-e.cfg.Result
+	assert.Equal(t, StatusStarted, r.Status)
+
+	err = e.Cancel(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	r = <-e.cfg.Result
+	assert.Equal(t, StatusAborted, r.Status)
+
+	err = e.Cancel(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	_ = e.Deinit(ctx)
+}
+
+func TestExecQueue(t *testing.T) {
+	e := initExec([]string{"sleep", "5"})
+	ctx := context.Background()
+
+	e.cfg.Result = make(chan *Result, 4)
+	e.exec.Concurrency = 1
+
+	_ = e.Init(ctx)
+
+	running := Request{Id: "running", Params: map[string]string{}}
+	queued := Request{Id: "queued", Params: map[string]string{}}
+
+	// Dispatch returns without waiting for the running command
+	err := e.Dispatch(ctx, &running)
+	assert.Equal(t, nil, err)
+
+	r := <-e.cfg.Result
+	assert.Equal(t, StatusStarted, r.Status)
+
+	err = e.Dispatch(ctx, &queued)
+	assert.Equal(t, nil, err)
+
+	err = e.Cancel(ctx, &queued)
+	assert.Equal(t, nil, err)
+
+	r = <-e.cfg.Result
+	assert.Equal(t, StatusAborted, r.Status)
+	assert.Equal(t, &queued, r.Request)
+
+	_ = e.Cancel(ctx, &running)
+
+	r = <-e.cfg.Result
+	assert.Equal(t, StatusAborted, r.Status)
+	assert.Equal(t, &running, r.Request)
+
+	_ = e.Deinit(ctx)
+}
@@ -0,0 +1,513 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+const (
+	jenkinsBackoff      = time.Minute
+	jenkinsBuild        = "/buildWithParameters"
+	jenkinsCancel       = "/queue/cancelItem?id="
+	jenkinsCrumb        = "/crumbIssuer/api/json"
+	jenkinsJob          = "/job/"
+	jenkinsPoll         = 5 * time.Second
+	jenkinsQueue        = "api/json"
+	jenkinsResult       = "api/json"
+	jenkinsStop         = "stop"
+	jenkinsTimeout      = 30 * time.Second
+	jenkinsQueueTimeout = 10 * time.Minute
+	jenkinsType         = "application/x-www-form-urlencoded"
+)
+
+const (
+	jenkinsAborted  = "ABORTED"
+	jenkinsNotBuilt = "NOT_BUILT"
+	jenkinsSuccess  = "SUCCESS"
+	jenkinsUnstable = "UNSTABLE"
+)
+
+var (
+	errJenkinsNotFound = errors.New("not found")
+)
+
+type jenkinsDispatcher struct {
+	cfg     *Config
+	jenkins config.Jenkins
+	name    string
+	builds  sync.Map
+	cancel  context.CancelFunc
+	client  *http.Client
+	ctx     context.Context
+	poll    time.Duration
+	timeout time.Duration
+	wg      sync.WaitGroup
+}
+
+// jenkinsTrack to store queue item and build of one request to cancel
+type jenkinsTrack struct {
+	cancel    context.CancelFunc
+	cancelled bool
+	location  string
+	mutex     sync.Mutex
+	url       string
+}
+
+type jenkinsCrumbResult struct {
+	Crumb             string `json:"crumb"`
+	CrumbRequestField string `json:"crumbRequestField"`
+}
+
+type jenkinsQueueResult struct {
+	Cancelled  bool                    `json:"cancelled"`
+	Executable jenkinsExecutableResult `json:"executable"`
+	Why        string                  `json:"why"`
+}
+
+type jenkinsExecutableResult struct {
+	Number int    `json:"number"`
+	Url    string `json:"url"`
+}
+
+type jenkinsBuildResult struct {
+	Building bool   `json:"building"`
+	Result   string `json:"result"`
+}
+
+func newJenkins(cfg *Config, d *config.Dispatch) Dispatcher {
+	return &jenkinsDispatcher{
+		cfg:     cfg,
+		jenkins: d.Jenkins,
+		name:    d.Name,
+		poll:    time.Duration(d.Jenkins.PollSeconds) * time.Second,
+		timeout: time.Duration(d.Jenkins.QueueTimeoutSeconds) * time.Second,
+	}
+}
+
+func (j *jenkinsDispatcher) Init(_ context.Context) error {
+	j.cfg.Logger.Debug("jenkins: Init")
+
+	if j.jenkins.Url == "" || j.jenkins.Job == "" {
+		return errors.New("invalid url or job")
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create jar")
+	}
+
+	j.client = &http.Client{
+		Jar:     jar,
+		Timeout: jenkinsTimeout,
+	}
+
+	if j.poll <= 0 {
+		j.poll = jenkinsPoll
+	}
+
+	if j.timeout <= 0 {
+		j.timeout = jenkinsQueueTimeout
+	}
+
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	return nil
+}
+
+func (j *jenkinsDispatcher) Deinit(_ context.Context) error {
+	j.cfg.Logger.Debug("jenkins: Deinit")
+
+	if j.cancel != nil {
+		j.cancel()
+	}
+
+	j.wg.Wait()
+
+	return nil
+}
+
+// Dispatch to build and track request in background, and builds are not reported once stopped by Deinit
+func (j *jenkinsDispatcher) Dispatch(_ context.Context, req *Request) error {
+	ctx, cancel := context.WithCancel(j.ctx)
+	t := &jenkinsTrack{cancel: cancel}
+
+	if req.Id != "" {
+		j.builds.Store(req.Id, t)
+	}
+
+	j.wg.Add(1)
+
+	go func() {
+		defer func() {
+			cancel()
+			if req.Id != "" {
+				j.builds.Delete(req.Id)
+			}
+			j.wg.Done()
+		}()
+		location, err := j.build(ctx, req.Params)
+		if err != nil {
+			j.fail(ctx, t, req, "", errors.Wrap(err, "failed to build"))
+			return
+		}
+		j.cfg.Logger.Debug("jenkins: Dispatch", "name", j.name, "queue", location)
+		t.mutex.Lock()
+		t.location = location
+		cancelled := t.cancelled
+		t.mutex.Unlock()
+		// Queue item is cancelled here if cancelled while building
+		if cancelled {
+			_ = j.cancelQueue(context.Background(), location)
+			notify(j.cfg, j.name, req, StatusAborted, location)
+			return
+		}
+		r, err := j.track(ctx, location)
+		if err != nil {
+			j.fail(ctx, t, req, location, err)
+			return
+		}
+		t.mutex.Lock()
+		t.url = r.Url
+		t.mutex.Unlock()
+		j.cfg.Logger.Info("jenkins: Dispatch", "name", j.name, "number", r.Number, "url", r.Url)
+		notify(j.cfg, j.name, req, StatusStarted, r.Url)
+		status, err := j.wait(ctx, r.Url)
+		if err != nil {
+			if j.stopped(t) {
+				j.cfg.Logger.Info("jenkins: Dispatch", "name", j.name, "url", r.Url, "stop", "tracking")
+				return
+			}
+			j.cfg.Logger.Error("jenkins: Dispatch", "name", j.name, "url", r.Url, "error", err.Error())
+			status = StatusAborted
+		}
+		j.cfg.Logger.Info("jenkins: Dispatch", "name", j.name, "number", r.Number, "status", status)
+		notify(j.cfg, j.name, req, status, r.Url)
+	}()
+
+	return nil
+}
+
+// fail to report build failed, or aborted if cancelled, and nothing is reported if stopped by Deinit
+func (j *jenkinsDispatcher) fail(ctx context.Context, t *jenkinsTrack, req *Request, location string, err error) {
+	if j.stopped(t) {
+		j.cfg.Logger.Info("jenkins: Dispatch", "name", j.name, "queue", location, "stop", "tracking")
+		return
+	}
+
+	j.cfg.Logger.Error("jenkins: Dispatch", "name", j.name, "queue", location, "error", err.Error())
+
+	if ctx.Err() != nil {
+		notify(j.cfg, j.name, req, StatusAborted, location)
+	} else {
+		notify(j.cfg, j.name, req, StatusFailed, location)
+	}
+}
+
+// stopped to check if tracking is stopped by Deinit rather than cancelled, since builds keep running in Jenkins
+func (j *jenkinsDispatcher) stopped(t *jenkinsTrack) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return !t.cancelled && j.ctx.Err() != nil
+}
+
+func (j *jenkinsDispatcher) Cancel(ctx context.Context, req *Request) error {
+	buf, ok := j.builds.Load(req.Id)
+	if !ok {
+		return nil
+	}
+
+	t := buf.(*jenkinsTrack)
+
+	t.mutex.Lock()
+	location, _url := t.location, t.url
+	t.cancelled = true
+	t.mutex.Unlock()
+
+	j.cfg.Logger.Info("jenkins: Cancel", "name", j.name, "job", req.Job, "queue", location, "url", _url)
+
+	// Stop build if started, otherwise cancel queue item if built
+	var err error
+
+	if _url != "" {
+		if !strings.HasSuffix(_url, "/") {
+			_url += "/"
+		}
+		err = j.post(ctx, _url+jenkinsStop)
+	} else if location != "" {
+		err = j.cancelQueue(ctx, location)
+	}
+
+	t.cancel()
+
+	if err != nil {
+		return errors.Wrap(err, "failed to cancel")
+	}
+
+	return nil
+}
+
+func (j *jenkinsDispatcher) cancelQueue(ctx context.Context, location string) error {
+	// e.g., "http://localhost:8080/queue/item/1/"
+	id := path.Base(strings.TrimSuffix(location, "/"))
+
+	return j.post(ctx, strings.TrimSuffix(j.jenkins.Url, "/")+jenkinsCancel+url.QueryEscape(id))
+}
+
+func (j *jenkinsDispatcher) build(ctx context.Context, data map[string]string) (string, error) {
+	form := url.Values{}
+
+	for key, val := range data {
+		form.Set(key, val)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.jobUrl()+jenkinsBuild, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to set request")
+	}
+
+	req.Header.Set("Content-Type", jenkinsType)
+
+	if err := j.auth(ctx, req); err != nil {
+		return "", errors.Wrap(err, "failed to auth")
+	}
+
+	rsp, err := j.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to send request")
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	_, _ = io.Copy(io.Discard, rsp.Body)
+
+	if rsp.StatusCode != http.StatusCreated {
+		return "", errors.New("invalid status " + strconv.Itoa(rsp.StatusCode))
+	}
+
+	location := rsp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("invalid location")
+	}
+
+	if !strings.HasSuffix(location, "/") {
+		location += "/"
+	}
+
+	return location, nil
+}
+
+// track to poll queue item until built, and retry transient errors with backoff until queue timeout
+func (j *jenkinsDispatcher) track(ctx context.Context, location string) (jenkinsExecutableResult, error) {
+	timer := time.NewTimer(j.timeout)
+	defer timer.Stop()
+
+	backoff := j.poll
+
+	for {
+		delay := j.poll
+		r, err := j.queue(ctx, location)
+		if err != nil {
+			if errors.Is(err, errJenkinsNotFound) || ctx.Err() != nil {
+				return jenkinsExecutableResult{}, errors.Wrap(err, "failed to query queue")
+			}
+			j.cfg.Logger.Warn("jenkins: track", "name", j.name, "queue", location, "error", err.Error())
+			delay, backoff = backoff, min(backoff*2, jenkinsBackoff)
+		} else {
+			if r.Cancelled {
+				return jenkinsExecutableResult{}, errors.New("queue item cancelled")
+			}
+			if r.Executable.Number > 0 {
+				return r.Executable, nil
+			}
+			j.cfg.Logger.Debug("jenkins: track", "name", j.name, "queue", location, "why", r.Why)
+			backoff = j.poll
+		}
+		select {
+		case <-time.After(delay):
+		case <-timer.C:
+			if err != nil {
+				return jenkinsExecutableResult{}, errors.Wrap(err, "queue item timeout")
+			}
+			return jenkinsExecutableResult{}, errors.New("queue item timeout")
+		case <-ctx.Done():
+			return jenkinsExecutableResult{}, ctx.Err()
+		}
+	}
+}
+
+// wait to poll build until done, and retry transient errors with backoff until failed longer than queue timeout
+func (j *jenkinsDispatcher) wait(ctx context.Context, _url string) (string, error) {
+	if !strings.HasSuffix(_url, "/") {
+		_url += "/"
+	}
+
+	backoff := j.poll
+
+	var failed time.Time
+
+	for {
+		delay := j.poll
+		var buf jenkinsBuildResult
+		if err := j.get(ctx, _url+jenkinsResult, &buf); err != nil {
+			if failed.IsZero() {
+				failed = time.Now()
+			}
+			if errors.Is(err, errJenkinsNotFound) || ctx.Err() != nil || time.Since(failed) >= j.timeout {
+				return "", errors.Wrap(err, "failed to query build")
+			}
+			j.cfg.Logger.Warn("jenkins: wait", "name", j.name, "url", _url, "error", err.Error())
+			delay, backoff = backoff, min(backoff*2, jenkinsBackoff)
+		} else {
+			if !buf.Building && buf.Result != "" {
+				return jenkinsStatus(buf.Result), nil
+			}
+			backoff = j.poll
+			failed = time.Time{}
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (j *jenkinsDispatcher) queue(ctx context.Context, location string) (jenkinsQueueResult, error) {
+	var buf jenkinsQueueResult
+
+	if err := j.get(ctx, location+jenkinsQueue, &buf); err != nil {
+		return buf, err
+	}
+
+	return buf, nil
+}
+
+func (j *jenkinsDispatcher) auth(ctx context.Context, req *http.Request) error {
+	if j.jenkins.Username != "" && j.jenkins.Token != "" {
+		req.SetBasicAuth(j.jenkins.Username, j.jenkins.Token)
+	}
+
+	var buf jenkinsCrumbResult
+
+	if err := j.get(ctx, strings.TrimSuffix(j.jenkins.Url, "/")+jenkinsCrumb, &buf); err != nil {
+		// CSRF protection is disabled if crumb issuer is not found
+		if errors.Is(err, errJenkinsNotFound) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to fetch crumb")
+	}
+
+	if buf.CrumbRequestField != "" && buf.Crumb != "" {
+		req.Header.Set(buf.CrumbRequestField, buf.Crumb)
+	}
+
+	return nil
+}
+
+func (j *jenkinsDispatcher) post(ctx context.Context, _url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, _url, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "failed to set request")
+	}
+
+	if err := j.auth(ctx, req); err != nil {
+		return errors.Wrap(err, "failed to auth")
+	}
+
+	rsp, err := j.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	_, _ = io.Copy(io.Discard, rsp.Body)
+
+	// Jenkins redirects after stop and cancel
+	if rsp.StatusCode >= http.StatusBadRequest {
+		return errors.New("invalid status " + strconv.Itoa(rsp.StatusCode))
+	}
+
+	return nil
+}
+
+func (j *jenkinsDispatcher) get(ctx context.Context, _url string, data any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, _url, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "failed to set request")
+	}
+
+	if j.jenkins.Username != "" && j.jenkins.Token != "" {
+		req.SetBasicAuth(j.jenkins.Username, j.jenkins.Token)
+	}
+
+	rsp, err := j.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	if rsp.StatusCode == http.StatusNotFound {
+		return errJenkinsNotFound
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return errors.New("invalid status " + strconv.Itoa(rsp.StatusCode))
+	}
+
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read")
+	}
+
+	if err := json.Unmarshal(b, data); err != nil {
+		return errors.Wrap(err, "failed to unmarshal")
+	}
+
+	return nil
+}
+
+func (j *jenkinsDispatcher) jobUrl() string {
+	// e.g., "folder/name" replaced with "/job/folder/job/name"
+	buf := strings.Split(strings.Trim(j.jenkins.Job, "/"), "/")
+
+	for i := range buf {
+		buf[i] = url.PathEscape(buf[i])
+	}
+
+	return strings.TrimSuffix(j.jenkins.Url, "/") + jenkinsJob + strings.Join(buf, jenkinsJob)
+}
+
+func jenkinsStatus(result string) string {
+	switch result {
+	case jenkinsSuccess:
+		return StatusSucceeded
+	case jenkinsUnstable:
+		return StatusUnstable
+	case jenkinsAborted, jenkinsNotBuilt:
+		return StatusAborted
+	default:
+		return StatusFailed
+	}
+}
@@ -0,0 +1,264 @@
+package dispatch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/params"
+)
+
+func initJenkins(url string) jenkinsDispatcher {
+	d := initDispatch()
+
+	return jenkinsDispatcher{
+		cfg: d.cfg,
+		jenkins: config.Jenkins{
+			Job:      "folder/name",
+			Token:    "token",
+			Url:      url,
+			Username: "user",
+		},
+		name:    typeJenkins,
+		poll:    time.Millisecond,
+		timeout: time.Second,
+	}
+}
+
+func TestJenkinsJobUrl(t *testing.T) {
+	j := initJenkins("http://localhost:8080/")
+	assert.Equal(t, "http://localhost:8080/job/folder/job/name", j.jobUrl())
+
+	j.jenkins.Job = "name"
+	assert.Equal(t, "http://localhost:8080/job/name", j.jobUrl())
+}
+
+// nolint: funlen
+func TestJenkinsDispatch(t *testing.T) {
+	var count int32
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(jenkinsCrumb, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"crumb":"crumb","crumbRequestField":"Jenkins-Crumb"}`))
+	})
+
+	mux.HandleFunc("/job/folder/job/name"+jenkinsBuild, func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "user" || pass != "token" || r.Header.Get("Jenkins-Crumb") != "crumb" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.FormValue(params.ParamsGerritBranch) != "main" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Location", "http://"+r.Host+"/queue/item/1")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	mux.HandleFunc("/queue/item/1/api/json", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 2 {
+			_, _ = w.Write([]byte(`{"why":"waiting"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"executable":{"number":2,"url":"http://` + r.Host + `/job/folder/job/name/2/"}}`))
+	})
+
+	mux.HandleFunc("/job/folder/job/name/2/api/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"building":false,"result":"SUCCESS"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	j := initJenkins(server.URL)
+	j.cfg.Result = make(chan *Result, 2)
+	ctx := context.Background()
+
+	err := j.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	location, err := j.build(ctx, map[string]string{params.ParamsGerritBranch: "main"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, server.URL+"/queue/item/1/", location)
+
+	r, err := j.track(ctx, location)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, r.Number)
+	assert.Equal(t, server.URL+"/job/folder/job/name/2/", r.Url)
+
+	_, err = j.build(ctx, map[string]string{})
+	assert.NotEqual(t, nil, err)
+
+	err = j.Dispatch(ctx, &Request{Params: map[string]string{params.ParamsGerritBranch: "main"}})
+	assert.Equal(t, nil, err)
+
+	res := <-j.cfg.Result
+	assert.Equal(t, StatusStarted, res.Status)
+	assert.Equal(t, server.URL+"/job/folder/job/name/2/", res.Url)
+
+	res = <-j.cfg.Result
+	assert.Equal(t, StatusSucceeded, res.Status)
+
+	_ = j.Deinit(ctx)
+
+	// Build failed in background is reported instead of blocking dispatch
+	j.jenkins.Job = "invalid"
+
+	_ = j.Init(ctx)
+
+	err = j.Dispatch(ctx, &Request{Params: map[string]string{}})
+	assert.Equal(t, nil, err)
+
+	res = <-j.cfg.Result
+	assert.Equal(t, StatusFailed, res.Status)
+
+	_ = j.Deinit(ctx)
+	assert.Equal(t, jenkinsTimeout, j.client.Timeout)
+}
+
+func TestJenkinsStatus(t *testing.T) {
+	assert.Equal(t, StatusSucceeded, jenkinsStatus("SUCCESS"))
+	assert.Equal(t, StatusFailed, jenkinsStatus("FAILURE"))
+	assert.Equal(t, StatusUnstable, jenkinsStatus("UNSTABLE"))
+	assert.Equal(t, StatusAborted, jenkinsStatus("ABORTED"))
+}
+
+func TestJenkinsCancel(t *testing.T) {
+	var cancelled, polled, stopped int32
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/job/folder/job/name"+jenkinsBuild, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "http://"+r.Host+"/queue/item/3")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	mux.HandleFunc("/queue/item/3/api/json", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&polled, 1)
+		if atomic.LoadInt32(&cancelled) == 0 {
+			_, _ = w.Write([]byte(`{"why":"waiting"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"cancelled":true}`))
+	})
+
+	mux.HandleFunc("/queue/cancelItem", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Query().Get("id") == "3" {
+			atomic.AddInt32(&cancelled, 1)
+		}
+	})
+
+	mux.HandleFunc("/job/folder/job/name/4/stop", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&stopped, 1)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	j := initJenkins(server.URL)
+	j.cfg.Result = make(chan *Result, 2)
+	ctx := context.Background()
+
+	_ = j.Init(ctx)
+
+	req := Request{Id: "id", Params: map[string]string{}}
+
+	err := j.Dispatch(ctx, &req)
+	assert.Equal(t, nil, err)
+
+	for atomic.LoadInt32(&polled) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	err = j.Cancel(ctx, &req)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled))
+
+	res := <-j.cfg.Result
+	assert.Equal(t, StatusAborted, res.Status)
+
+	// Build still queued is not reported once stopped
+	atomic.StoreInt32(&cancelled, 0)
+	atomic.StoreInt32(&polled, 0)
+
+	err = j.Dispatch(ctx, &Request{Id: "stop", Params: map[string]string{}})
+	assert.Equal(t, nil, err)
+
+	for atomic.LoadInt32(&polled) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = j.Deinit(ctx)
+	assert.Equal(t, 0, len(j.cfg.Result))
+
+	_, cancel := context.WithCancel(ctx)
+	j.builds.Store("build", &jenkinsTrack{cancel: cancel, url: server.URL + "/job/folder/job/name/4/"})
+
+	err = j.Cancel(ctx, &Request{Id: "build"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stopped))
+}
+
+func TestJenkinsRetry(t *testing.T) {
+	var queue, build int32
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/queue/item/1/api/json", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&queue, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"executable":{"number":2,"url":"http://` + r.Host + `/job/name/2/"}}`))
+	})
+
+	mux.HandleFunc("/job/name/2/api/json", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&build, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"building":false,"result":"SUCCESS"}`))
+	})
+
+	mux.HandleFunc("/job/name/3/api/json", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	j := initJenkins(server.URL)
+	ctx := context.Background()
+
+	_ = j.Init(ctx)
+
+	defer func(j *jenkinsDispatcher, ctx context.Context) {
+		_ = j.Deinit(ctx)
+	}(&j, ctx)
+
+	// Transient errors are retried instead of aborting build
+	r, err := j.track(ctx, server.URL+"/queue/item/1/")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, r.Number)
+
+	status, err := j.wait(ctx, r.Url)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, StatusSucceeded, status)
+
+	_, err = j.track(ctx, server.URL+"/queue/item/invalid/")
+	assert.NotEqual(t, nil, err)
+
+	now := time.Now()
+
+	_, err = j.wait(ctx, server.URL+"/job/name/3/")
+	assert.NotEqual(t, nil, err)
+	assert.GreaterOrEqual(t, time.Since(now), j.timeout)
+}
@@ -0,0 +1,43 @@
+package dispatch
+
+import (
+	"context"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+type logDispatcher struct {
+	cfg  *Config
+	name string
+}
+
+func newLog(cfg *Config, d *config.Dispatch) Dispatcher {
+	return &logDispatcher{
+		cfg:  cfg,
+		name: d.Name,
+	}
+}
+
+func (l *logDispatcher) Init(_ context.Context) error {
+	l.cfg.Logger.Debug("log: Init")
+
+	return nil
+}
+
+func (l *logDispatcher) Deinit(_ context.Context) error {
+	l.cfg.Logger.Debug("log: Deinit")
+
+	return nil
+}
+
+func (l *logDispatcher) Dispatch(_ context.Context, req *Request) error {
+	l.cfg.Logger.Info("log: Dispatch", "name", l.name, "job", req.Job, "params", req.Params)
+
+	return nil
+}
+
+func (l *logDispatcher) Cancel(_ context.Context, req *Request) error {
+	l.cfg.Logger.Info("log: Cancel", "name", l.name, "job", req.Job, "id", req.Id)
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+const (
+	webhookBackoff   = 1 * time.Second
+	webhookBody      = `{"event":{{json .Event}},"params":{{json .Params}}}`
+	webhookSignature = "X-Trigger-Signature"
+	webhookTimeout   = 30 * time.Second
+	webhookType      = "application/json;charset=utf-8"
+)
+
+type webhookDispatcher struct {
+	cfg     *Config
+	webhook config.Webhook
+	name    string
+	backoff time.Duration
+	cancel  context.CancelFunc
+	client  *http.Client
+	ctx     context.Context
+	tmpl    *template.Template
+	wg      sync.WaitGroup
+}
+
+type webhookData struct {
+	Event  *events.Event
+	Params map[string]string
+}
+
+func newWebhook(cfg *Config, d *config.Dispatch) Dispatcher {
+	return &webhookDispatcher{
+		cfg:     cfg,
+		webhook: d.Webhook,
+		name:    d.Name,
+		backoff: time.Duration(d.Webhook.Retry.BackoffSeconds) * time.Second,
+	}
+}
+
+func (w *webhookDispatcher) Init(_ context.Context) error {
+	w.cfg.Logger.Debug("webhook: Init")
+
+	var err error
+
+	if len(w.webhook.Urls) == 0 {
+		return errors.New("invalid urls")
+	}
+
+	body := w.webhook.Body
+	if body == "" {
+		body = webhookBody
+	}
+
+	w.tmpl, err = template.New(w.name).Funcs(template.FuncMap{"json": webhookJson}).Parse(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse body")
+	}
+
+	if w.backoff <= 0 {
+		w.backoff = webhookBackoff
+	}
+
+	timeout := time.Duration(w.webhook.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = webhookTimeout
+	}
+
+	w.client = &http.Client{
+		Timeout: timeout,
+	}
+
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	return nil
+}
+
+func (w *webhookDispatcher) Deinit(_ context.Context) error {
+	w.cfg.Logger.Debug("webhook: Deinit")
+
+	if w.cancel != nil {
+		w.cancel()
+	}
+
+	w.wg.Wait()
+
+	return nil
+}
+
+func (w *webhookDispatcher) Dispatch(_ context.Context, req *Request) error {
+	body, err := w.render(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to render body")
+	}
+
+	for _, item := range w.webhook.Urls {
+		w.wg.Add(1)
+		go func(url string) {
+			defer w.wg.Done()
+			if err := w.send(w.ctx, url, body); err != nil {
+				// Deliveries stopped on shutdown are not reported as failed
+				if w.ctx.Err() != nil {
+					w.cfg.Logger.Debug("webhook: Dispatch", "name", w.name, "url", url, "error", err.Error())
+					return
+				}
+				w.cfg.Logger.Error("webhook: Dispatch", "name", w.name, "url", url, "error", err.Error())
+				notify(w.cfg, w.name, req, StatusFailed, url)
+				return
+			}
+			w.cfg.Logger.Info("webhook: Dispatch", "name", w.name, "url", url)
+		}(item)
+	}
+
+	return nil
+}
+
+func (w *webhookDispatcher) Cancel(_ context.Context, req *Request) error {
+	// Builds triggered by webhook are owned by the receiver
+	w.cfg.Logger.Debug("webhook: Cancel", "name", w.name, "job", req.Job, "id", req.Id)
+
+	return nil
+}
+
+func (w *webhookDispatcher) render(req *Request) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := w.tmpl.Execute(&buf, webhookData{Event: req.Event, Params: req.Params}); err != nil {
+		return nil, errors.Wrap(err, "failed to execute template")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (w *webhookDispatcher) send(ctx context.Context, url string, body []byte) error {
+	var err error
+
+	backoff := w.backoff
+
+	for i := 0; i <= w.webhook.Retry.Count; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err = w.post(ctx, url, body); err == nil {
+			return nil
+		}
+		w.cfg.Logger.Debug("webhook: send", "name", w.name, "url", url, "attempt", i+1, "error", err.Error())
+	}
+
+	return errors.Wrap(err, "failed to post after "+strconv.Itoa(w.webhook.Retry.Count+1)+" attempts")
+}
+
+func (w *webhookDispatcher) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to set request")
+	}
+
+	req.Header.Set("Content-Type", webhookType)
+
+	for key, val := range w.webhook.Headers {
+		req.Header.Set(key, val)
+	}
+
+	if w.webhook.Secret != "" {
+		req.Header.Set(webhookSignature, "sha256="+webhookSign(w.webhook.Secret, body))
+	}
+
+	rsp, err := w.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	_, _ = io.Copy(io.Discard, rsp.Body)
+
+	if rsp.StatusCode < http.StatusOK || rsp.StatusCode >= http.StatusMultipleChoices {
+		return errors.New("invalid status " + strconv.Itoa(rsp.StatusCode))
+	}
+
+	return nil
+}
+
+func webhookJson(data any) (string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func webhookSign(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	_, _ = h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
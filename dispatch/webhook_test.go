@@ -0,0 +1,141 @@
+package dispatch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/events"
+	"github.com/gerrittrigger/trigger/params"
+)
+
+func initWebhook(url string) webhookDispatcher {
+	d := initDispatch()
+
+	return webhookDispatcher{
+		cfg: d.cfg,
+		webhook: config.Webhook{
+			Headers: map[string]string{"X-Name": "trigger"},
+			Retry: config.Retry{
+				Count: 2,
+			},
+			Secret:         "secret",
+			TimeoutSeconds: 5,
+			Urls:           []string{url},
+		},
+		name:    typeWebhook,
+		backoff: time.Millisecond,
+	}
+}
+
+func TestWebhookRender(t *testing.T) {
+	w := initWebhook("http://localhost")
+	ctx := context.Background()
+
+	err := w.Init(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 5*time.Second, w.client.Timeout)
+
+	w.webhook.TimeoutSeconds = 0
+
+	err = w.Init(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, webhookTimeout, w.client.Timeout)
+
+	req := Request{
+		Event:  &events.Event{Type: events.EventsPatchsetCreated},
+		Params: map[string]string{params.ParamsGerritBranch: "main"},
+	}
+
+	b, err := w.render(&req)
+	assert.Equal(t, nil, err)
+	assert.Contains(t, string(b), `{"event":{"type":"patchset-created",`)
+	assert.Contains(t, string(b), `"params":{"GERRIT_BRANCH":"main"}}`)
+
+	w.webhook.Body = "{{.Event.Type}} {{index .Params \"GERRIT_BRANCH\"}}"
+
+	err = w.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	b, err = w.render(&req)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "patchset-created main", string(b))
+
+	w.webhook.Body = "{{.Invalid"
+
+	err = w.Init(ctx)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestWebhookSend(t *testing.T) {
+	var count int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Name") != "trigger" || r.Header.Get(webhookSignature) != "sha256="+webhookSign("secret", body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&count, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	w := initWebhook(server.URL)
+	ctx := context.Background()
+
+	_ = w.Init(ctx)
+
+	err := w.send(ctx, server.URL, []byte("body"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+	w.webhook.Retry.Count = 0
+	w.webhook.Secret = "invalid"
+
+	err = w.send(ctx, server.URL, []byte("body"))
+	assert.NotEqual(t, nil, err)
+
+	err = w.Dispatch(ctx, &Request{Event: &events.Event{}, Params: map[string]string{}})
+	assert.Equal(t, nil, err)
+
+	_ = w.Deinit(ctx)
+}
+
+func TestWebhookDeinit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer server.Close()
+
+	w := initWebhook(server.URL)
+	w.backoff = time.Hour
+	w.cfg.Result = make(chan *Result, 1)
+
+	ctx := context.Background()
+
+	_ = w.Init(ctx)
+
+	err := w.Dispatch(ctx, &Request{Event: &events.Event{}, Params: map[string]string{}})
+	assert.Equal(t, nil, err)
+
+	// Retries waiting for backoff are stopped without reporting failure
+	start := time.Now()
+
+	_ = w.Deinit(ctx)
+
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.Equal(t, 0, len(w.cfg.Result))
+}
@@ -0,0 +1,204 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+// Trace to store the evaluation of event and project rules
+type Trace struct {
+	Events   []Rule `json:"events"`
+	Matched  bool   `json:"matched"`
+	Projects []Rule `json:"projects"`
+}
+
+// Rule to store the evaluation of one rule in config.Event or config.Project
+type Rule struct {
+	Checks  []Check `json:"checks"`
+	Index   int     `json:"index"`
+	Matched bool    `json:"matched"`
+}
+
+// Check to store the result of one field in rule, e.g., "branches"
+type Check struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason"`
+}
+
+func (t *Trace) String() string {
+	helper := func(kind string, rules []Rule) []string {
+		var buf []string
+		for _, r := range rules {
+			for _, c := range r.Checks {
+				buf = append(buf, fmt.Sprintf("%s[%d].%s passed=%t: %s", kind, r.Index, c.Name, c.Passed, c.Reason))
+			}
+		}
+		return buf
+	}
+
+	buf := []string{fmt.Sprintf("matched=%t", t.Matched)}
+	buf = append(buf, helper("events", t.Events)...)
+	buf = append(buf, helper("projects", t.Projects)...)
+
+	return strings.Join(buf, "; ")
+}
+
+func (f *filter) Explain(ctx context.Context, _events []config.Event, projects []config.Project, event *events.Event) (*Trace, error) {
+	t := Trace{
+		Events:   []Rule{},
+		Projects: []Rule{},
+	}
+
+	em := false
+
+	for i := range _events {
+		r := f.explainEvent(ctx, &_events[i], event)
+		r.Index = i
+		t.Events = append(t.Events, r)
+		em = em || r.Matched
+	}
+
+	pm := false
+
+	for i := range projects {
+		r := f.explainProject(ctx, &projects[i], event)
+		r.Index = i
+		t.Projects = append(t.Projects, r)
+		pm = pm || r.Matched
+	}
+
+	t.Matched = em && pm
+
+	return &t, nil
+}
+
+func (f *filter) explainEvent(ctx context.Context, cfg *config.Event, event *events.Event) Rule {
+	r := Rule{
+		Checks: []Check{},
+	}
+
+	name := f.eventName(ctx, cfg, event)
+	r.Checks = append(r.Checks, Check{"name", name, fmt.Sprintf("rule %q, event %q", cfg.Name, event.Type)})
+
+	if !name {
+		return r
+	}
+
+	switch event.Type {
+	case events.EventsCommentAdded:
+		a := f.eventCommentAdded(ctx, cfg, event)
+		r.Checks = append(r.Checks, Check{"commentAdded", a,
+			fmt.Sprintf("rule %s=%s, approvals %s", cfg.CommentAdded.VerdictCategory, cfg.CommentAdded.Value, f.explainApprovals(event))})
+		b := f.eventCommentAddedContainsRegularExpression(ctx, cfg, event)
+		r.Checks = append(r.Checks, Check{"commentAddedContainsRegularExpression", b,
+			fmt.Sprintf("rule %q, comment %q", cfg.CommentAddedContainsRegularExpression.Value, event.Comment)})
+		r.Matched = a || b
+	case events.EventsPatchsetCreated:
+		a := f.eventCommitMessage(ctx, cfg, event)
+		r.Checks = append(r.Checks, Check{"commitMessage", a,
+			fmt.Sprintf("rule %q, message %q", cfg.CommitMessage, event.Change.CommitMessage)})
+		b := f.eventPatchsetCreated(ctx, cfg, event)
+		r.Checks = append(r.Checks, Check{"patchsetCreated", b, f.explainPatchsetCreated(ctx, cfg, event)})
+		c := f.eventUploaderName(ctx, cfg, event)
+		r.Checks = append(r.Checks, Check{"uploaderName", c,
+			fmt.Sprintf("rule %q, uploader %q, patchset uploader %q", cfg.UploaderName, event.Uploader.Name, event.PatchSet.Uploader.Name)})
+		r.Matched = a && b && c
+	default:
+		r.Matched = true
+	}
+
+	return r
+}
+
+func (f *filter) explainApprovals(event *events.Event) string {
+	buf := make([]string, len(event.Approvals))
+
+	for i := range event.Approvals {
+		buf[i] = event.Approvals[i].Type + "=" + event.Approvals[i].Value
+	}
+
+	return "[" + strings.Join(buf, " ") + "]"
+}
+
+func (f *filter) explainPatchsetCreated(ctx context.Context, cfg *config.Event, event *events.Event) string {
+	var buf []string
+
+	if f.eventPatchsetExcludeDrafts(ctx, cfg, event) {
+		buf = append(buf, "draft excluded")
+	}
+
+	if f.eventPatchsetExcludeNoCodeChange(ctx, cfg, event) {
+		buf = append(buf, "no code change excluded")
+	}
+
+	if f.eventPatchsetExcludePrivateChanges(ctx, cfg, event) {
+		buf = append(buf, "private change excluded")
+	}
+
+	if f.eventPatchsetExcludeTrivialRebase(ctx, cfg, event) {
+		buf = append(buf, "trivial rebase excluded")
+	}
+
+	if f.eventPatchsetExcludeWIPChanges(ctx, cfg, event) {
+		buf = append(buf, "wip change excluded")
+	}
+
+	if len(buf) == 0 {
+		return "no exclusion"
+	}
+
+	return strings.Join(buf, ", ")
+}
+
+func (f *filter) explainProject(ctx context.Context, cfg *config.Project, event *events.Event) Rule {
+	r := Rule{
+		Checks: []Check{},
+	}
+
+	files := make([]string, len(event.PatchSet.Files))
+	for i := range event.PatchSet.Files {
+		files[i] = event.PatchSet.Files[i].File
+	}
+
+	a := f.projectRepo(ctx, cfg, event)
+	r.Checks = append(r.Checks, Check{"repo", a, fmt.Sprintf("rule %s, project %q", f.explainMatch(cfg.Repo), event.Project)})
+
+	b := f.projectBranches(ctx, cfg, event)
+	r.Checks = append(r.Checks, Check{"branches", b,
+		fmt.Sprintf("rule %s, branch %q", f.explainMatches(cfg.Branches), event.Change.Branch)})
+
+	c := f.projectFilePaths(ctx, cfg, event)
+	r.Checks = append(r.Checks, Check{"filePaths", c,
+		fmt.Sprintf("rule %s, files %q", f.explainMatches(cfg.FilePaths), files)})
+
+	d := !f.projectForbiddenFilePaths(ctx, cfg, event)
+	r.Checks = append(r.Checks, Check{"forbiddenFilePaths", d,
+		fmt.Sprintf("rule %s, files %q", f.explainMatches(cfg.ForbiddenFilePaths), files)})
+
+	e := f.projectTopics(ctx, cfg, event)
+	r.Checks = append(r.Checks, Check{"topics", e,
+		fmt.Sprintf("rule %s, topic %q", f.explainMatches(cfg.Topics), event.Change.Topic)})
+
+	r.Matched = a && b && c && d && e
+
+	return r
+}
+
+func (f *filter) explainMatch(match config.Match) string {
+	return fmt.Sprintf("%s:%q", match.Type, match.Pattern)
+}
+
+func (f *filter) explainMatches(match []config.Match) string {
+	buf := make([]string, len(match))
+
+	for i := range match {
+		buf[i] = f.explainMatch(match[i])
+	}
+
+	return "[" + strings.Join(buf, " ") + "]"
+}
@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+// nolint: funlen
+func TestExplain(t *testing.T) {
+	f := initFilter()
+	ctx := context.Background()
+
+	_events := []config.Event{
+		{
+			Name: events.EventsCommentAdded,
+		},
+		{
+			CommitMessage: "Init*",
+			Name:          events.EventsPatchsetCreated,
+			PatchsetCreated: config.PatchsetCreated{
+				ExcludeWIPChanges: true,
+			},
+		},
+	}
+
+	projects := []config.Project{
+		{
+			Branches: []config.Match{{Pattern: "main", Type: matchPlain}},
+			ForbiddenFilePaths: []config.Match{
+				{Pattern: "**/*.md", Type: matchPath},
+			},
+			Repo: config.Match{Pattern: "test", Type: matchPlain},
+		},
+	}
+
+	event := events.Event{
+		Change: events.Change{
+			Branch:        "main",
+			CommitMessage: "Initial commit",
+			WIP:           true,
+		},
+		PatchSet: events.PatchSet{
+			Files: []events.File{{File: "README.md"}},
+		},
+		Project: "test",
+		Type:    events.EventsPatchsetCreated,
+	}
+
+	r, err := f.Explain(ctx, _events, projects, &event)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, r.Matched)
+
+	assert.Equal(t, 2, len(r.Events))
+	assert.Equal(t, false, r.Events[0].Matched)
+	assert.Equal(t, 1, len(r.Events[0].Checks))
+	assert.Equal(t, "name", r.Events[0].Checks[0].Name)
+	assert.Equal(t, false, r.Events[0].Checks[0].Passed)
+
+	assert.Equal(t, 1, r.Events[1].Index)
+	assert.Equal(t, false, r.Events[1].Matched)
+	assert.Equal(t, "commitMessage", r.Events[1].Checks[1].Name)
+	assert.Equal(t, true, r.Events[1].Checks[1].Passed)
+	assert.Equal(t, "patchsetCreated", r.Events[1].Checks[2].Name)
+	assert.Equal(t, false, r.Events[1].Checks[2].Passed)
+	assert.Equal(t, "wip change excluded", r.Events[1].Checks[2].Reason)
+
+	assert.Equal(t, 1, len(r.Projects))
+	assert.Equal(t, false, r.Projects[0].Matched)
+	assert.Equal(t, "forbiddenFilePaths", r.Projects[0].Checks[3].Name)
+	assert.Equal(t, false, r.Projects[0].Checks[3].Passed)
+
+	event.Change.WIP = false
+	event.PatchSet.Files = []events.File{{File: "main.go"}}
+
+	r, err = f.Explain(ctx, _events, projects, &event)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, r.Matched)
+
+	m, err := f.Run(ctx, _events, projects, &event)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, r.Matched, m)
+
+	assert.Contains(t, r.String(), "matched=true")
+	assert.Contains(t, r.String(), "projects[0].branches passed=true")
+}
@@ -3,9 +3,12 @@ package filter
 import (
 	"context"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
 
 	"github.com/gerrittrigger/go-antpath/antpath"
 	"github.com/gerrittrigger/trigger/config"
@@ -23,6 +26,7 @@ type Filter interface {
 	Init(context.Context) error
 	Deinit(context.Context) error
 	Run(context.Context, []config.Event, []config.Project, *events.Event) (bool, error)
+	Explain(context.Context, []config.Event, []config.Project, *events.Event) (*Trace, error)
 }
 
 type Config struct {
@@ -31,12 +35,16 @@ type Config struct {
 }
 
 type filter struct {
-	cfg *Config
+	cfg     *Config
+	antpath *antpath.AntPathMatcher
+	regexps *sync.Map
 }
 
 func New(_ context.Context, cfg *Config) Filter {
 	return &filter{
-		cfg: cfg,
+		cfg:     cfg,
+		antpath: antpath.New(),
+		regexps: &sync.Map{},
 	}
 }
 
@@ -44,9 +52,48 @@ func DefaultConfig() *Config {
 	return &Config{}
 }
 
+// Init compiles all patterns in config
 func (f *filter) Init(_ context.Context) error {
 	f.cfg.Logger.Debug("filter: Init")
 
+	f.antpath = antpath.New()
+	f.regexps = &sync.Map{}
+
+	// Clone to avoid appending jobs into the spare capacity of config
+	_events := slices.Clone(f.cfg.Config.Spec.Trigger.Events)
+	projects := slices.Clone(f.cfg.Config.Spec.Trigger.Projects)
+
+	for i := range f.cfg.Config.Spec.Trigger.Jobs {
+		_events = append(_events, f.cfg.Config.Spec.Trigger.Jobs[i].Events...)
+		projects = append(projects, f.cfg.Config.Spec.Trigger.Jobs[i].Projects...)
+	}
+
+	for i := range _events {
+		for _, item := range []string{
+			_events[i].CommentAddedContainsRegularExpression.Value,
+			_events[i].CommitMessage,
+			_events[i].UploaderName,
+		} {
+			if _, err := f.regexp(item); err != nil {
+				return errors.Wrap(err, "failed to compile "+item)
+			}
+		}
+	}
+
+	for i := range projects {
+		var match []config.Match
+		match = append(match, projects[i].Branches...)
+		match = append(match, projects[i].FilePaths...)
+		match = append(match, projects[i].ForbiddenFilePaths...)
+		match = append(match, projects[i].Repo)
+		match = append(match, projects[i].Topics...)
+		for _, item := range match {
+			if err := f.compileMatch(item); err != nil {
+				return errors.Wrap(err, "failed to compile "+item.Pattern)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -61,6 +108,13 @@ func (f *filter) Run(ctx context.Context, _events []config.Event, projects []con
 		return false, nil
 	}
 
+	if f.cfg.Logger.IsDebug() {
+		if t, err := f.Explain(ctx, _events, projects, event); err == nil {
+			f.cfg.Logger.Debug("filter: Run", "type", event.Type, "project", event.Project, "change", event.Change.Number,
+				"trace", t.String())
+		}
+	}
+
 	if !f.filterEvents(ctx, _events, event) || !f.filterProjects(ctx, projects, event) {
 		return false, nil
 	}
@@ -151,9 +205,7 @@ func (f *filter) eventCommentAddedContainsRegularExpression(_ context.Context, c
 		return true
 	}
 
-	m, _ := regexp.MatchString(cfg.CommentAddedContainsRegularExpression.Value, event.Comment)
-
-	return m
+	return f.regexpMatch(cfg.CommentAddedContainsRegularExpression.Value, event.Comment)
 }
 
 func (f *filter) eventCommitMessage(_ context.Context, cfg *config.Event, event *events.Event) bool {
@@ -161,9 +213,7 @@ func (f *filter) eventCommitMessage(_ context.Context, cfg *config.Event, event
 		return true
 	}
 
-	m, _ := regexp.MatchString(cfg.CommitMessage, event.Change.CommitMessage)
-
-	return m
+	return f.regexpMatch(cfg.CommitMessage, event.Change.CommitMessage)
 }
 
 func (f *filter) eventPatchsetCreated(ctx context.Context, cfg *config.Event, event *events.Event) bool {
@@ -225,11 +275,11 @@ func (f *filter) eventUploaderName(_ context.Context, cfg *config.Event, event *
 		return true
 	}
 
-	if m, _ := regexp.MatchString(cfg.UploaderName, event.Uploader.Name); m {
+	if f.regexpMatch(cfg.UploaderName, event.Uploader.Name) {
 		return true
 	}
 
-	if m, _ := regexp.MatchString(cfg.UploaderName, event.PatchSet.Uploader.Name); m {
+	if f.regexpMatch(cfg.UploaderName, event.PatchSet.Uploader.Name) {
 		return true
 	}
 
@@ -338,17 +388,60 @@ func (f *filter) projectMatch(match config.Match, data string) bool {
 	m := false
 
 	if strings.EqualFold(match.Type, matchPath) {
-		a := antpath.New()
-		m = a.Match(match.Pattern, data)
+		m = f.antpath.Match(match.Pattern, data)
 	} else if strings.EqualFold(match.Type, matchPlain) {
 		if match.Pattern == data {
 			m = true
 		}
 	} else if strings.EqualFold(match.Type, matchRegExp) {
-		m, _ = regexp.MatchString(match.Pattern, data)
+		m = f.regexpMatch(match.Pattern, data)
 	} else {
 		m = false
 	}
 
 	return m
 }
+
+func (f *filter) compileMatch(match config.Match) (err error) {
+	if match.Pattern == "" {
+		return nil
+	}
+
+	if strings.EqualFold(match.Type, matchPath) {
+		// Invalid pattern panics in antpath since its regexp is not compiled
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.New("invalid path pattern")
+			}
+		}()
+		_ = f.antpath.Match(match.Pattern, match.Pattern)
+	} else if strings.EqualFold(match.Type, matchRegExp) {
+		_, err = f.regexp(match.Pattern)
+	}
+
+	return err
+}
+
+func (f *filter) regexp(pattern string) (*regexp.Regexp, error) {
+	if r, ok := f.regexps.Load(pattern); ok {
+		return r.(*regexp.Regexp), nil
+	}
+
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	f.regexps.Store(pattern, r)
+
+	return r, nil
+}
+
+func (f *filter) regexpMatch(pattern, data string) bool {
+	r, err := f.regexp(pattern)
+	if err != nil {
+		return false
+	}
+
+	return r.MatchString(data)
+}
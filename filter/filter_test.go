@@ -2,18 +2,23 @@ package filter
 
 import (
 	"context"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/gerrittrigger/go-antpath/antpath"
 	"github.com/gerrittrigger/trigger/config"
 	"github.com/gerrittrigger/trigger/events"
 )
 
 func initFilter() filter {
 	f := filter{
-		cfg: DefaultConfig(),
+		cfg:     DefaultConfig(),
+		antpath: antpath.New(),
+		regexps: &sync.Map{},
 	}
 
 	f.cfg.Config = config.Config{}
@@ -1070,3 +1075,147 @@ func TestProjectMatch(t *testing.T) {
 	b = f.projectMatch(m, "test.txt")
 	assert.Equal(t, false, b)
 }
+
+func TestInit(t *testing.T) {
+	f := initFilter()
+	ctx := context.Background()
+
+	f.cfg.Config.Spec.Trigger.Jobs = []config.Job{
+		{
+			Events: []config.Event{{CommitMessage: "Init*", Name: events.EventsPatchsetCreated}},
+			Projects: []config.Project{
+				{
+					Branches:  []config.Match{{Pattern: "main", Type: matchPlain}},
+					FilePaths: []config.Match{{Pattern: "**/*.go", Type: matchPath}},
+					Repo:      config.Match{Pattern: "t.*", Type: matchRegExp},
+				},
+			},
+		},
+	}
+
+	err := f.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	_, ok := f.regexps.Load("Init*")
+	assert.Equal(t, true, ok)
+
+	_, ok = f.regexps.Load("t.*")
+	assert.Equal(t, true, ok)
+
+	f.cfg.Config.Spec.Trigger.Jobs[0].Events[0].CommitMessage = "Init(["
+
+	err = f.Init(ctx)
+	assert.NotEqual(t, nil, err)
+
+	f.cfg.Config.Spec.Trigger.Jobs[0].Events[0].CommitMessage = ""
+	f.cfg.Config.Spec.Trigger.Jobs[0].Projects[0].Repo.Pattern = "t.*(["
+
+	err = f.Init(ctx)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestInitConfig(t *testing.T) {
+	f := initFilter()
+	ctx := context.Background()
+
+	// Spare capacity of config is not written by patterns of jobs
+	_events := make([]config.Event, 1, 2)
+	projects := make([]config.Project, 1, 2)
+
+	f.cfg.Config.Spec.Trigger.Events = _events
+	f.cfg.Config.Spec.Trigger.Projects = projects
+	f.cfg.Config.Spec.Trigger.Jobs = []config.Job{
+		{
+			Events:   []config.Event{{CommitMessage: "Init*"}},
+			Projects: []config.Project{{Repo: config.Match{Pattern: "t.*", Type: matchRegExp}}},
+		},
+	}
+
+	err := f.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, "", _events[:2][1].CommitMessage)
+	assert.Equal(t, "", projects[:2][1].Repo.Pattern)
+}
+
+func benchmarkEvent(count int) events.Event {
+	files := make([]events.File, count)
+
+	for i := range files {
+		files[i] = events.File{
+			File: "src/module" + strconv.Itoa(i%100) + "/file" + strconv.Itoa(i) + ".go",
+		}
+	}
+
+	return events.Event{
+		Change: events.Change{
+			Branch:        "main",
+			CommitMessage: "Initial commit",
+			Topic:         "topic",
+		},
+		PatchSet: events.PatchSet{
+			Files: files,
+			Uploader: events.Account{
+				Name: "admin",
+			},
+		},
+		Project: "platform/test",
+		Type:    events.EventsPatchsetCreated,
+	}
+}
+
+func benchmarkRun(b *testing.B, count int) {
+	f := initFilter()
+	ctx := context.Background()
+
+	_events := []config.Event{
+		{
+			CommitMessage: "^Init.*",
+			Name:          events.EventsPatchsetCreated,
+			UploaderName:  "ad.*",
+		},
+	}
+
+	projects := []config.Project{
+		{
+			Branches: []config.Match{{Pattern: "ma.*", Type: matchRegExp}},
+			FilePaths: []config.Match{
+				{Pattern: "docs/**/*.md", Type: matchPath},
+				{Pattern: "**/*.go", Type: matchPath},
+			},
+			ForbiddenFilePaths: []config.Match{
+				{Pattern: "**/*.bin", Type: matchPath},
+				{Pattern: `.*\.lock$`, Type: matchRegExp},
+			},
+			Repo:   config.Match{Pattern: "platform/.*", Type: matchRegExp},
+			Topics: []config.Match{{Pattern: "top.*", Type: matchRegExp}},
+		},
+	}
+
+	f.cfg.Config.Spec.Trigger.Events = _events
+	f.cfg.Config.Spec.Trigger.Projects = projects
+
+	if err := f.Init(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	event := benchmarkEvent(count)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if m, _ := f.Run(ctx, _events, projects, &event); !m {
+			b.Fatal("not matched")
+		}
+	}
+
+	b.ReportMetric(float64(count*b.N)/b.Elapsed().Seconds(), "files/s")
+}
+
+func BenchmarkRun100(b *testing.B) {
+	benchmarkRun(b, 100)
+}
+
+func BenchmarkRun10000(b *testing.B) {
+	benchmarkRun(b, 10000)
+}
@@ -26,4 +26,5 @@ const (
 	ParamsGerritRefspec               = "GERRIT_REFSPEC"
 	ParamsGerritScheme                = "GERRIT_SCHEME"
 	ParamsGerritTopic                 = "GERRIT_TOPIC"
+	ParamsTriggerBuildId              = "TRIGGER_BUILD_ID"
 )
@@ -38,7 +38,8 @@ type Config struct {
 }
 
 type playback struct {
-	cfg *Config
+	cfg  *Config
+	name string
 }
 
 type httpResult struct {
@@ -47,8 +48,16 @@ type httpResult struct {
 }
 
 func New(_ context.Context, cfg *Config) Playback {
+	name := fileName
+
+	// Cursor is stored per server, e.g., "gerrit.events-base64.playback"
+	if len(cfg.Config.Spec.Connects) != 0 {
+		name = cfg.Config.Spec.Connect.Name + "." + fileName
+	}
+
 	return &playback{
-		cfg: cfg,
+		cfg:  cfg,
+		name: name,
 	}
 }
 
@@ -71,11 +80,11 @@ func (p *playback) Deinit(_ context.Context) error {
 func (p *playback) Load(ctx context.Context) ([]string, error) {
 	p.cfg.Logger.Debug("trigger: Load")
 
-	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
+	if _, err := os.Stat(p.name); errors.Is(err, os.ErrNotExist) {
 		return []string{}, nil
 	}
 
-	event, err := p.loadCache(ctx, fileName)
+	event, err := p.loadCache(ctx, p.name)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load cache")
 	}
@@ -103,7 +112,7 @@ func (p *playback) Store(_ context.Context, event string) error {
 
 	b := base64.StdEncoding.EncodeToString([]byte(event))
 
-	return os.WriteFile(fileName, []byte(b), fileMode)
+	return os.WriteFile(p.name, []byte(b), fileMode)
 }
 
 func (p *playback) loadCache(_ context.Context, name string) (events.Event, error) {
@@ -20,7 +20,8 @@ var (
 
 func initPlayback() playback {
 	p := playback{
-		cfg: DefaultConfig(),
+		cfg:  DefaultConfig(),
+		name: fileName,
 	}
 
 	p.cfg.Config = config.Config{}
@@ -57,6 +58,19 @@ func TestStore(t *testing.T) {
 	_ = os.Remove(fileName)
 }
 
+func TestNew(t *testing.T) {
+	cfg := DefaultConfig()
+
+	p := New(context.Background(), cfg).(*playback)
+	assert.Equal(t, fileName, p.name)
+
+	cfg.Config.Spec.Connect.Name = "gerrit"
+	cfg.Config.Spec.Connects = []config.Connect{{Name: "gerrit"}}
+
+	p = New(context.Background(), cfg).(*playback)
+	assert.Equal(t, "gerrit."+fileName, p.name)
+}
+
 func TestLoadCache(t *testing.T) {
 	p := initPlayback()
 	ctx := context.Background()
@@ -0,0 +1,194 @@
+package queue
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+)
+
+// Item to store one event in queue
+type Item struct {
+	Data string    `json:"data"`
+	Id   string    `json:"id"`
+	Time time.Time `json:"time"`
+	seq  uint64
+}
+
+// Snapshot to store pending events in delivery order and in-flight events waiting for acknowledgement
+type Snapshot struct {
+	Inflight []Item `json:"inflight"`
+	Paused   bool   `json:"paused"`
+	Pending  []Item `json:"pending"`
+}
+
+// buffer to store events between producer and consumer for inspection and control
+type buffer struct {
+	inflight []*Item
+	mutex    sync.Mutex
+	paused   bool
+	pending  []*Item
+	popped   chan struct{}
+	pushed   chan struct{}
+	seq      uint64
+}
+
+func newBuffer() *buffer {
+	return &buffer{
+		popped: make(chan struct{}, 1),
+		pushed: make(chan struct{}, 1),
+	}
+}
+
+func (b *buffer) push(data string) *Item {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.seq++
+
+	item := &Item{
+		Data: data,
+		Id:   strconv.FormatUint(b.seq, 10),
+		Time: time.Now(),
+		seq:  b.seq,
+	}
+
+	b.pending = append(b.pending, item)
+	b.signal(b.pushed)
+
+	return item
+}
+
+// pop to move the first pending event to in-flight, nil is returned if paused or empty
+func (b *buffer) pop() *Item {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.paused || len(b.pending) == 0 {
+		return nil
+	}
+
+	item := b.pending[0]
+	b.pending = b.pending[1:]
+	b.inflight = append(b.inflight, item)
+	b.signal(b.popped)
+
+	return item
+}
+
+func (b *buffer) ack(data string) (*Item, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	i := slices.IndexFunc(b.inflight, func(item *Item) bool { return item.Data == data })
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+
+	item := b.inflight[i]
+	b.inflight = slices.Delete(b.inflight, i, i+1)
+
+	return item, nil
+}
+
+func (b *buffer) remove(id string) (*Item, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	i := slices.IndexFunc(b.pending, func(item *Item) bool { return item.Id == id })
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+
+	item := b.pending[i]
+	b.pending = slices.Delete(b.pending, i, i+1)
+	b.signal(b.popped)
+
+	return item, nil
+}
+
+// prioritize to move pending event to the front
+func (b *buffer) prioritize(id string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	i := slices.IndexFunc(b.pending, func(item *Item) bool { return item.Id == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	item := b.pending[i]
+	b.pending = slices.Insert(slices.Delete(b.pending, i, i+1), 0, item)
+
+	return nil
+}
+
+func (b *buffer) pause(paused bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.paused = paused
+	b.signal(b.pushed)
+}
+
+func (b *buffer) size() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.pending)
+}
+
+func (b *buffer) snapshot() *Snapshot {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := &Snapshot{
+		Inflight: make([]Item, len(b.inflight)),
+		Paused:   b.paused,
+		Pending:  make([]Item, len(b.pending)),
+	}
+
+	for i := range b.inflight {
+		s.Inflight[i] = *b.inflight[i]
+	}
+
+	for i := range b.pending {
+		s.Pending[i] = *b.pending[i]
+	}
+
+	return s
+}
+
+// deliver to send pending events to consumer until context is done
+func (b *buffer) deliver(ctx context.Context, events chan string) {
+	for {
+		item := b.pop()
+		if item == nil {
+			select {
+			case <-b.pushed:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case events <- item.Data:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *buffer) signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,530 @@
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	diskCommit  = "commit.json"
+	diskHeader  = 8
+	diskMode    = 0o600
+	diskPerm    = 0o700
+	diskSegment = 64 * 1024 * 1024
+	diskSuffix  = ".log"
+	diskWindow  = 1024
+)
+
+// diskPosition to store the offset of one record in segments
+type diskPosition struct {
+	Offset  int64  `json:"offset"`
+	Segment uint64 `json:"segment"`
+}
+
+// diskPending to store the record read ahead waiting for acknowledgement
+type diskPending struct {
+	acked bool
+	next  diskPosition
+	seq   uint64
+}
+
+// diskQueue to store events in append-only segment log, each record is
+// length (4 bytes) + CRC32 (4 bytes) + data, and the position of acknowledged
+// records is committed to survive restart
+type diskQueue struct {
+	cfg     *Config
+	dir     string
+	size    int64
+	buffer  *buffer
+	cancel  context.CancelFunc
+	commit  diskPosition
+	events  chan string
+	group   sync.WaitGroup
+	mutex   sync.Mutex
+	notify  chan struct{}
+	pending []diskPending
+	writer  *os.File
+	wpos    int64
+	wseg    uint64
+}
+
+func newDisk(cfg *Config, dir string) Queue {
+	return &diskQueue{
+		cfg:    cfg,
+		dir:    dir,
+		size:   cfg.Config.Spec.Queue.SegmentBytes,
+		buffer: newBuffer(),
+		events: make(chan string),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (d *diskQueue) Init(_ context.Context) error {
+	d.cfg.Logger.Debug("disk: Init")
+
+	if d.dir == "" {
+		return errors.New("invalid path")
+	}
+
+	if d.size <= 0 {
+		d.size = diskSegment
+	}
+
+	if err := os.MkdirAll(d.dir, diskPerm); err != nil {
+		return errors.Wrap(err, "failed to make dir")
+	}
+
+	if err := d.loadCommit(); err != nil {
+		return errors.Wrap(err, "failed to load commit")
+	}
+
+	segments, err := d.segments()
+	if err != nil {
+		return errors.Wrap(err, "failed to list segments")
+	}
+
+	if len(segments) == 0 {
+		segments = []uint64{d.commit.Segment}
+	}
+
+	// Segments before commit may be removed already
+	if d.commit.Segment < segments[0] {
+		d.commit = diskPosition{Segment: segments[0]}
+	}
+
+	d.wseg = segments[len(segments)-1]
+
+	if err := d.openWriter(); err != nil {
+		return errors.Wrap(err, "failed to open writer")
+	}
+
+	// Commit past truncated records is moved back to read the records written next
+	if d.commit.Segment == d.wseg && d.commit.Offset > d.wpos {
+		d.commit.Offset = d.wpos
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	d.group.Add(2)
+
+	go func() {
+		d.read(ctx)
+		d.group.Done()
+	}()
+
+	go func() {
+		d.buffer.deliver(ctx, d.events)
+		d.group.Done()
+	}()
+
+	return nil
+}
+
+func (d *diskQueue) Deinit(_ context.Context) error {
+	d.cfg.Logger.Debug("disk: Deinit")
+
+	return nil
+}
+
+func (d *diskQueue) Put(_ context.Context, data string) error {
+	buf := make([]byte, diskHeader+len(data))
+
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE([]byte(data)))
+	copy(buf[diskHeader:], data)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.writer == nil {
+		return errors.New("queue closed")
+	}
+
+	if d.wpos > 0 && d.wpos+int64(len(buf)) > d.size {
+		_ = d.writer.Close()
+		d.wseg++
+		if err := d.openWriter(); err != nil {
+			return errors.Wrap(err, "failed to roll segment")
+		}
+	}
+
+	if _, err := d.writer.Write(buf); err != nil {
+		return errors.Wrap(err, "failed to write")
+	}
+
+	if err := d.writer.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync")
+	}
+
+	d.wpos += int64(len(buf))
+
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (d *diskQueue) Get(_ context.Context) (chan string, error) {
+	return d.events, nil
+}
+
+func (d *diskQueue) Ack(_ context.Context, data string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	item, err := d.buffer.ack(data)
+	if err != nil {
+		return errors.Wrap(err, "invalid data")
+	}
+
+	return d.acknowledge(item.seq)
+}
+
+func (d *diskQueue) Close(_ context.Context) error {
+	d.cfg.Logger.Debug("disk: Close")
+
+	if d.cancel != nil {
+		d.cancel()
+		d.group.Wait()
+	}
+
+	close(d.events)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.writer != nil {
+		_ = d.writer.Close()
+		d.writer = nil
+	}
+
+	return nil
+}
+
+func (d *diskQueue) List(_ context.Context) (*Snapshot, error) {
+	return d.buffer.snapshot(), nil
+}
+
+// Remove to drop pending record, and it is committed as acknowledged to be skipped after restart
+func (d *diskQueue) Remove(_ context.Context, id string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	item, err := d.buffer.remove(id)
+	if err != nil {
+		return err
+	}
+
+	return d.acknowledge(item.seq)
+}
+
+// Prioritize to reorder delivery only, and records are still committed in order of segments
+func (d *diskQueue) Prioritize(_ context.Context, id string) error {
+	return d.buffer.prioritize(id)
+}
+
+func (d *diskQueue) Pause(_ context.Context) error {
+	d.cfg.Logger.Info("disk: Pause")
+
+	d.buffer.pause(true)
+
+	return nil
+}
+
+func (d *diskQueue) Resume(_ context.Context) error {
+	d.cfg.Logger.Info("disk: Resume")
+
+	d.buffer.pause(false)
+
+	return nil
+}
+
+func (d *diskQueue) acknowledge(seq uint64) error {
+	for i := range d.pending {
+		if d.pending[i].seq == seq {
+			d.pending[i].acked = true
+			break
+		}
+	}
+
+	// Commit the contiguous acknowledged records only
+	n := 0
+
+	for n < len(d.pending) && d.pending[n].acked {
+		d.commit = d.pending[n].next
+		n++
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	d.pending = d.pending[n:]
+
+	if err := d.storeCommit(); err != nil {
+		return errors.Wrap(err, "failed to store commit")
+	}
+
+	d.removeSegments()
+
+	return nil
+}
+
+func (d *diskQueue) read(ctx context.Context) {
+	d.mutex.Lock()
+	pos := d.commit
+	d.mutex.Unlock()
+
+	var f *os.File
+
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+
+	for {
+		if f == nil {
+			var err error
+			if f, err = os.Open(d.segmentName(pos.Segment)); err != nil {
+				d.cfg.Logger.Error("disk: read", "error", err.Error())
+				return
+			}
+		}
+		// Bound records read ahead of delivery
+		if d.buffer.size() >= diskWindow {
+			select {
+			case <-d.buffer.popped:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		// Segment is complete if writer moves to the next one before reading, otherwise records are read up to writer
+		d.mutex.Lock()
+		complete := pos.Segment < d.wseg
+		end := d.wpos
+		d.mutex.Unlock()
+		if complete {
+			info, err := f.Stat()
+			if err != nil {
+				d.cfg.Logger.Error("disk: read", "error", err.Error())
+				return
+			}
+			end = info.Size()
+		}
+		data, next, err := d.readRecord(f, pos, end)
+		if err == io.EOF {
+			if complete {
+				_ = f.Close()
+				f = nil
+				pos = diskPosition{Segment: pos.Segment + 1}
+				continue
+			}
+			select {
+			case <-d.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			// Corrupt record is skipped instead of blocking the following ones
+			d.cfg.Logger.Error("disk: read", "segment", pos.Segment, "offset", pos.Offset, "error", err.Error())
+			pos = next
+			continue
+		}
+		d.mutex.Lock()
+		item := d.buffer.push(data)
+		d.pending = append(d.pending, diskPending{next: next, seq: item.seq})
+		d.mutex.Unlock()
+		pos = next
+	}
+}
+
+// readRecord to read the record at pos before end, and return io.EOF at end or the position to skip corrupt record
+func (d *diskQueue) readRecord(f *os.File, pos diskPosition, end int64) (string, diskPosition, error) {
+	if pos.Offset >= end {
+		return "", pos, io.EOF
+	}
+
+	skip := diskPosition{Offset: end, Segment: pos.Segment}
+
+	if end-pos.Offset < diskHeader {
+		return "", skip, errors.New("invalid header")
+	}
+
+	header := make([]byte, diskHeader)
+
+	if _, err := f.ReadAt(header, pos.Offset); err != nil {
+		return "", skip, errors.Wrap(err, "failed to read header")
+	}
+
+	// Length is bounded by the remaining size to avoid allocating for corrupt header
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+
+	if size > end-pos.Offset-diskHeader {
+		return "", skip, errors.New("invalid length")
+	}
+
+	buf := make([]byte, size)
+
+	if _, err := f.ReadAt(buf, pos.Offset+diskHeader); err != nil {
+		return "", skip, errors.Wrap(err, "failed to read data")
+	}
+
+	next := diskPosition{Offset: pos.Offset + diskHeader + size, Segment: pos.Segment}
+
+	if crc32.ChecksumIEEE(buf) != binary.BigEndian.Uint32(header[4:8]) {
+		return "", next, errors.New("invalid checksum")
+	}
+
+	return string(buf), next, nil
+}
+
+func (d *diskQueue) openWriter() error {
+	name := d.segmentName(d.wseg)
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, diskMode)
+	if err != nil {
+		return errors.Wrap(err, "failed to open")
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to stat")
+	}
+
+	// Truncate partial record written before crash
+	pos := diskPosition{Segment: d.wseg}
+
+	for {
+		_, next, err := d.readRecord(f, pos, info.Size())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			d.cfg.Logger.Warn("disk: openWriter", "segment", pos.Segment, "offset", pos.Offset, "error", err.Error())
+			break
+		}
+		pos = next
+	}
+
+	if err := f.Truncate(pos.Offset); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to truncate")
+	}
+
+	if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to seek")
+	}
+
+	d.writer = f
+	d.wpos = pos.Offset
+
+	return nil
+}
+
+func (d *diskQueue) loadCommit() error {
+	buf, err := os.ReadFile(filepath.Join(d.dir, diskCommit))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return json.Unmarshal(buf, &d.commit)
+}
+
+func (d *diskQueue) storeCommit() error {
+	buf, err := json.Marshal(d.commit)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal")
+	}
+
+	name := filepath.Join(d.dir, diskCommit)
+
+	// Write and rename to replace commit atomically
+	f, err := os.OpenFile(name+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, diskMode)
+	if err != nil {
+		return errors.Wrap(err, "failed to open")
+	}
+
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to write")
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to sync")
+	}
+
+	_ = f.Close()
+
+	return os.Rename(name+".tmp", name)
+}
+
+func (d *diskQueue) removeSegments() {
+	segments, err := d.segments()
+	if err != nil {
+		return
+	}
+
+	for _, item := range segments {
+		if item >= d.commit.Segment {
+			break
+		}
+		if err := os.Remove(d.segmentName(item)); err != nil {
+			d.cfg.Logger.Error("disk: removeSegments", "error", err.Error())
+		}
+	}
+}
+
+func (d *diskQueue) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []uint64
+
+	for _, item := range entries {
+		if item.IsDir() || !strings.HasSuffix(item.Name(), diskSuffix) {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(item.Name(), diskSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		buf = append(buf, n)
+	}
+
+	sort.Slice(buf, func(i, j int) bool {
+		return buf[i] < buf[j]
+	})
+
+	return buf, nil
+}
+
+func (d *diskQueue) segmentName(id uint64) string {
+	return filepath.Join(d.dir, fmt.Sprintf("%020d%s", id, diskSuffix))
+}
@@ -0,0 +1,231 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+func initDisk(dir string, size int64) *diskQueue {
+	cfg := DefaultConfig()
+	cfg.Config = config.Config{}
+	cfg.Config.Spec.Queue = config.Queue{Path: dir, SegmentBytes: size, Type: typeDisk}
+
+	cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "queue",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	return newDisk(cfg, dir).(*diskQueue)
+}
+
+func TestDisk(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	d := initDisk(dir, 0)
+
+	err := d.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	for i := 0; i < 3; i++ {
+		err = d.Put(ctx, strconv.Itoa(i))
+		assert.Equal(t, nil, err)
+	}
+
+	r, err := d.Get(ctx)
+	assert.Equal(t, nil, err)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, strconv.Itoa(i), <-r)
+	}
+
+	err = d.Ack(ctx, "0")
+	assert.Equal(t, nil, err)
+
+	err = d.Ack(ctx, "2")
+	assert.Equal(t, nil, err)
+
+	err = d.Ack(ctx, "invalid")
+	assert.NotEqual(t, nil, err)
+
+	_ = d.Close(ctx)
+
+	// Unacknowledged record and the following ones are delivered again after restart
+	d = initDisk(dir, 0)
+
+	err = d.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	r, _ = d.Get(ctx)
+
+	assert.Equal(t, "1", <-r)
+	assert.Equal(t, "2", <-r)
+
+	_ = d.Ack(ctx, "1")
+	_ = d.Ack(ctx, "2")
+
+	err = d.Put(ctx, "3")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "3", <-r)
+
+	_ = d.Close(ctx)
+}
+
+func TestDiskSegment(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	d := initDisk(dir, 16)
+
+	_ = d.Init(ctx)
+
+	for i := 0; i < 3; i++ {
+		err := d.Put(ctx, "data"+strconv.Itoa(i))
+		assert.Equal(t, nil, err)
+	}
+
+	segments, _ := d.segments()
+	assert.Equal(t, 3, len(segments))
+
+	r, _ := d.Get(ctx)
+
+	for i := 0; i < 3; i++ {
+		buf := <-r
+		assert.Equal(t, "data"+strconv.Itoa(i), buf)
+		_ = d.Ack(ctx, buf)
+	}
+
+	segments, _ = d.segments()
+	assert.Equal(t, 1, len(segments))
+
+	_ = d.Close(ctx)
+}
+
+func TestDiskTruncate(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	d := initDisk(dir, 0)
+
+	_ = d.Init(ctx)
+	_ = d.Put(ctx, "data")
+	_ = d.Close(ctx)
+
+	// Append partial record as written before crash
+	f, _ := os.OpenFile(filepath.Join(dir, "00000000000000000000.log"), os.O_APPEND|os.O_WRONLY, 0o600)
+	_, _ = f.Write([]byte{0, 0, 0, 9, 1})
+	_ = f.Close()
+
+	d = initDisk(dir, 0)
+
+	err := d.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	_ = d.Put(ctx, "next")
+
+	r, _ := d.Get(ctx)
+
+	assert.Equal(t, "data", <-r)
+	assert.Equal(t, "next", <-r)
+
+	_ = d.Close(ctx)
+}
+
+func TestDiskCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	d := initDisk(dir, 16)
+
+	_ = d.Init(ctx)
+
+	for i := 0; i < 3; i++ {
+		_ = d.Put(ctx, "data"+strconv.Itoa(i))
+	}
+
+	_ = d.Close(ctx)
+
+	// Corrupt data in the first segment and length in the second one
+	f, _ := os.OpenFile(filepath.Join(dir, "00000000000000000000.log"), os.O_WRONLY, 0o600)
+	_, _ = f.WriteAt([]byte("x"), 8)
+	_ = f.Close()
+
+	f, _ = os.OpenFile(filepath.Join(dir, "00000000000000000001.log"), os.O_WRONLY, 0o600)
+	_, _ = f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, 0)
+	_ = f.Close()
+
+	// Append header with invalid length to the last segment
+	f, _ = os.OpenFile(filepath.Join(dir, "00000000000000000002.log"), os.O_APPEND|os.O_WRONLY, 0o600)
+	_, _ = f.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	_ = f.Close()
+
+	d = initDisk(dir, 0)
+
+	err := d.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	_ = d.Put(ctx, "next")
+
+	r, _ := d.Get(ctx)
+
+	assert.Equal(t, "data2", <-r)
+	assert.Equal(t, "next", <-r)
+
+	_ = d.Close(ctx)
+}
+
+func TestDiskRemove(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	d := initDisk(dir, 0)
+
+	_ = d.Init(ctx)
+	_ = d.Pause(ctx)
+
+	for i := 0; i < 3; i++ {
+		_ = d.Put(ctx, strconv.Itoa(i))
+	}
+
+	var s *Snapshot
+
+	for s == nil || len(s.Pending) < 3 {
+		s, _ = d.List(ctx)
+	}
+
+	err := d.Remove(ctx, s.Pending[0].Id)
+	assert.Equal(t, nil, err)
+
+	err = d.Prioritize(ctx, s.Pending[2].Id)
+	assert.Equal(t, nil, err)
+
+	_ = d.Resume(ctx)
+
+	r, _ := d.Get(ctx)
+
+	assert.Equal(t, "2", <-r)
+	assert.Equal(t, "1", <-r)
+
+	// Record acknowledged out of order is delivered again after restart
+	_ = d.Ack(ctx, "2")
+	_ = d.Close(ctx)
+
+	d = initDisk(dir, 0)
+
+	_ = d.Init(ctx)
+
+	r, _ = d.Get(ctx)
+
+	assert.Equal(t, "1", <-r)
+	assert.Equal(t, "2", <-r)
+
+	_ = d.Close(ctx)
+}
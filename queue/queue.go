@@ -2,18 +2,31 @@ package queue
 
 import (
 	"context"
+	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-hclog"
 
 	"github.com/gerrittrigger/trigger/config"
 )
 
+const (
+	typeDisk   = "disk"
+	typeMemory = "memory"
+)
+
 type Queue interface {
 	Init(context.Context) error
 	Deinit(context.Context) error
 	Put(context.Context, string) error
 	Get(context.Context) (chan string, error)
+	Ack(context.Context, string) error
 	Close(context.Context) error
+	List(context.Context) (*Snapshot, error)
+	Remove(context.Context, string) error
+	Prioritize(context.Context, string) error
+	Pause(context.Context) error
+	Resume(context.Context) error
 }
 
 type Config struct {
@@ -22,13 +35,26 @@ type Config struct {
 }
 
 type queue struct {
+	buffer *buffer
+	cancel context.CancelFunc
 	cfg    *Config
+	done   chan struct{}
 	events chan string
 }
 
 func New(_ context.Context, cfg *Config) Queue {
+	if strings.ToLower(cfg.Config.Spec.Queue.Type) == typeDisk {
+		dir := cfg.Config.Spec.Queue.Path
+		if len(cfg.Config.Spec.Connects) != 0 {
+			dir = filepath.Join(dir, cfg.Config.Spec.Connect.Name)
+		}
+		return newDisk(cfg, dir)
+	}
+
 	return &queue{
+		buffer: newBuffer(),
 		cfg:    cfg,
+		done:   make(chan struct{}),
 		events: make(chan string),
 	}
 }
@@ -40,6 +66,14 @@ func DefaultConfig() *Config {
 func (q *queue) Init(_ context.Context) error {
 	q.cfg.Logger.Debug("queue: Init")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	go func() {
+		q.buffer.deliver(ctx, q.events)
+		close(q.done)
+	}()
+
 	return nil
 }
 
@@ -50,7 +84,7 @@ func (q *queue) Deinit(_ context.Context) error {
 }
 
 func (q *queue) Put(_ context.Context, data string) error {
-	q.events <- data
+	_ = q.buffer.push(data)
 	return nil
 }
 
@@ -58,7 +92,53 @@ func (q *queue) Get(_ context.Context) (chan string, error) {
 	return q.events, nil
 }
 
+func (q *queue) Ack(_ context.Context, data string) error {
+	if _, err := q.buffer.ack(data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (q *queue) Close(_ context.Context) error {
+	if q.cancel != nil {
+		q.cancel()
+		<-q.done
+	}
+
 	close(q.events)
+
+	return nil
+}
+
+func (q *queue) List(_ context.Context) (*Snapshot, error) {
+	return q.buffer.snapshot(), nil
+}
+
+func (q *queue) Remove(_ context.Context, id string) error {
+	if _, err := q.buffer.remove(id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (q *queue) Prioritize(_ context.Context, id string) error {
+	return q.buffer.prioritize(id)
+}
+
+func (q *queue) Pause(_ context.Context) error {
+	q.cfg.Logger.Info("queue: Pause")
+
+	q.buffer.pause(true)
+
+	return nil
+}
+
+func (q *queue) Resume(_ context.Context) error {
+	q.cfg.Logger.Info("queue: Resume")
+
+	q.buffer.pause(false)
+
 	return nil
 }
@@ -11,9 +11,11 @@ import (
 	"github.com/gerrittrigger/trigger/config"
 )
 
-func initQueue() queue {
-	q := queue{
+func initQueue() *queue {
+	q := &queue{
+		buffer: newBuffer(),
 		cfg:    DefaultConfig(),
+		done:   make(chan struct{}),
 		events: make(chan string),
 	}
 
@@ -31,9 +33,11 @@ func TestQueue(t *testing.T) {
 	q := initQueue()
 	ctx := context.Background()
 
+	_ = q.Init(ctx)
+
 	defer func(q *queue, ctx context.Context) {
 		_ = q.Close(ctx)
-	}(&q, ctx)
+	}(q, ctx)
 
 	done := make(chan bool, 1)
 
@@ -58,3 +62,55 @@ L:
 		}
 	}
 }
+
+func TestQueueAdmin(t *testing.T) {
+	q := initQueue()
+	ctx := context.Background()
+
+	_ = q.Init(ctx)
+
+	defer func(q *queue, ctx context.Context) {
+		_ = q.Close(ctx)
+	}(q, ctx)
+
+	err := q.Pause(ctx)
+	assert.Equal(t, nil, err)
+
+	for i := 0; i < 3; i++ {
+		_ = q.Put(ctx, strconv.Itoa(i))
+	}
+
+	s, err := q.List(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, s.Paused)
+	assert.Equal(t, 3, len(s.Pending))
+	assert.Equal(t, 0, len(s.Inflight))
+
+	err = q.Remove(ctx, s.Pending[0].Id)
+	assert.Equal(t, nil, err)
+
+	err = q.Remove(ctx, "invalid")
+	assert.Equal(t, ErrNotFound, err)
+
+	err = q.Prioritize(ctx, s.Pending[2].Id)
+	assert.Equal(t, nil, err)
+
+	err = q.Resume(ctx)
+	assert.Equal(t, nil, err)
+
+	r, _ := q.Get(ctx)
+
+	assert.Equal(t, "2", <-r)
+	assert.Equal(t, "1", <-r)
+
+	s, _ = q.List(ctx)
+	assert.Equal(t, false, s.Paused)
+	assert.Equal(t, 0, len(s.Pending))
+	assert.Equal(t, 2, len(s.Inflight))
+
+	_ = q.Ack(ctx, "2")
+
+	s, _ = q.List(ctx)
+	assert.Equal(t, 1, len(s.Inflight))
+	assert.Equal(t, "1", s.Inflight[0].Data)
+}
@@ -0,0 +1,182 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/dispatch"
+)
+
+// Quiet to delay requests of jobs for quiet period, and coalesce requests of the same change
+// into the one of the latest patchset
+type Quiet interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Put(context.Context, *dispatch.Request) error
+	Get(context.Context) (chan *dispatch.Request, error)
+	Close(context.Context) error
+}
+
+// pending to store the request waiting for quiet period
+type pending struct {
+	req   *dispatch.Request
+	timer *time.Timer
+}
+
+type quiet struct {
+	cfg      *Config
+	closed   bool
+	lock     sync.RWMutex
+	mutex    sync.Mutex
+	pendings map[string]*pending
+	periods  map[string]time.Duration
+	requests chan *dispatch.Request
+	stopped  bool
+}
+
+func NewQuiet(_ context.Context, cfg *Config) Quiet {
+	return &quiet{
+		cfg:      cfg,
+		pendings: map[string]*pending{},
+		periods:  map[string]time.Duration{},
+		requests: make(chan *dispatch.Request),
+	}
+}
+
+func (q *quiet) Init(_ context.Context) error {
+	q.cfg.Logger.Debug("quiet: Init")
+
+	for _, item := range q.cfg.Config.Spec.Trigger.Jobs {
+		if item.QuietSeconds > 0 {
+			q.periods[item.Name] = time.Duration(item.QuietSeconds) * time.Second
+		}
+	}
+
+	return nil
+}
+
+func (q *quiet) Deinit(_ context.Context) error {
+	q.cfg.Logger.Debug("quiet: Deinit")
+
+	return nil
+}
+
+func (q *quiet) Put(ctx context.Context, req *dispatch.Request) error {
+	period := q.periods[req.Job]
+
+	// Requests out of change are never coalesced
+	if period <= 0 || req.Cancel || req.Event == nil || req.Event.Change.Number <= 0 {
+		return q.send(ctx, req)
+	}
+
+	key := req.Connect + "/" + req.Job + "/" + strconv.Itoa(req.Event.Change.Number)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return errors.New("queue closed")
+	}
+
+	if p, ok := q.pendings[key]; ok {
+		if req.Event.PatchSet.Number >= p.req.Event.PatchSet.Number {
+			q.cfg.Logger.Info("quiet: Put", "job", req.Job, "change", req.Event.Change.Number,
+				"patchset", req.Event.PatchSet.Number, "coalesce", p.req.Event.PatchSet.Number)
+			p.req = req
+		}
+		p.timer.Reset(period)
+		return nil
+	}
+
+	q.pendings[key] = &pending{
+		req: req,
+		timer: time.AfterFunc(period, func() {
+			q.fire(key)
+		}),
+	}
+
+	return nil
+}
+
+func (q *quiet) Get(_ context.Context) (chan *dispatch.Request, error) {
+	return q.requests, nil
+}
+
+// Close to dispatch pending requests at once and stop accepting requests
+func (q *quiet) Close(_ context.Context) error {
+	q.cfg.Logger.Debug("quiet: Close")
+
+	q.mutex.Lock()
+
+	q.closed = true
+
+	var buf []*dispatch.Request
+
+	for key, item := range q.pendings {
+		item.timer.Stop()
+		buf = append(buf, item.req)
+		delete(q.pendings, key)
+	}
+
+	q.mutex.Unlock()
+
+	// Consumer reads until requests closed, so pending requests are never dropped on cancelled context
+	for _, item := range buf {
+		_ = q.send(context.Background(), item)
+	}
+
+	// Wait for requests sent by fired timers
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.stopped {
+		return nil
+	}
+
+	q.stopped = true
+	close(q.requests)
+
+	return nil
+}
+
+func (q *quiet) fire(key string) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	if q.stopped {
+		return
+	}
+
+	q.mutex.Lock()
+
+	p, ok := q.pendings[key]
+	if ok {
+		delete(q.pendings, key)
+	}
+
+	q.mutex.Unlock()
+
+	if ok {
+		q.requests <- p.req
+	}
+}
+
+func (q *quiet) send(ctx context.Context, req *dispatch.Request) error {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	if q.stopped {
+		return errors.New("queue closed")
+	}
+
+	select {
+	case q.requests <- req:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
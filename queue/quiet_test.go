@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+func initQuiet() *quiet {
+	cfg := DefaultConfig()
+	cfg.Config = config.Config{}
+	cfg.Config.Spec.Trigger.Jobs = []config.Job{
+		{
+			Name:         "build",
+			QuietSeconds: 1,
+		},
+		{
+			Name: "lint",
+		},
+	}
+
+	cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "queue",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	return NewQuiet(context.Background(), cfg).(*quiet)
+}
+
+func initQuietRequest(job string, patchset int) *dispatch.Request {
+	return &dispatch.Request{
+		Connect: "gerrit",
+		Event: &events.Event{
+			Change:   events.Change{Number: 1},
+			PatchSet: events.PatchSet{Number: patchset},
+		},
+		Job: job,
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	q := initQuiet()
+	ctx := context.Background()
+
+	err := q.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	r, _ := q.Get(ctx)
+
+	go func() {
+		_ = q.Put(ctx, initQuietRequest("build", 1))
+		_ = q.Put(ctx, initQuietRequest("build", 3))
+		_ = q.Put(ctx, initQuietRequest("build", 2))
+		_ = q.Put(ctx, initQuietRequest("lint", 1))
+	}()
+
+	// Requests of jobs without quiet period are sent at once
+	req := <-r
+	assert.Equal(t, "lint", req.Job)
+
+	start := time.Now()
+
+	req = <-r
+	assert.Equal(t, "build", req.Job)
+	assert.Equal(t, 3, req.Event.PatchSet.Number)
+	assert.Greater(t, time.Since(start), 500*time.Millisecond)
+
+	_ = q.Close(ctx)
+
+	_, ok := <-r
+	assert.Equal(t, false, ok)
+
+	err = q.Put(ctx, initQuietRequest("lint", 1))
+	assert.NotEqual(t, nil, err)
+}
+
+func TestQuietClose(t *testing.T) {
+	q := initQuiet()
+	ctx := context.Background()
+
+	_ = q.Init(ctx)
+
+	r, _ := q.Get(ctx)
+
+	err := q.Put(ctx, initQuietRequest("build", 1))
+	assert.Equal(t, nil, err)
+
+	go func() {
+		_ = q.Close(ctx)
+	}()
+
+	// Pending requests are sent on close
+	req := <-r
+	assert.Equal(t, 1, req.Event.PatchSet.Number)
+
+	_, ok := <-r
+	assert.Equal(t, false, ok)
+}
@@ -112,6 +112,11 @@ func (r *report) fetchGeneral(_ context.Context, data map[string]string) (map[st
 	data[params.ParamsGerritHost] = r.cfg.Config.Spec.Connect.Hostname
 	data[params.ParamsGerritName] = r.cfg.Config.Spec.Connect.Name
 	data[params.ParamsGerritPort] = port
+
+	if r.cfg.Config.Spec.Connect.Ssh.Port > 0 {
+		data[params.ParamsGerritPort] = strconv.Itoa(r.cfg.Config.Spec.Connect.Ssh.Port)
+	}
+
 	data[params.ParamsGerritScheme] = scheme
 
 	return data, nil
@@ -9,6 +9,7 @@ import (
 
 	"github.com/gerrittrigger/trigger/config"
 	"github.com/gerrittrigger/trigger/events"
+	"github.com/gerrittrigger/trigger/params"
 )
 
 var (
@@ -91,6 +92,17 @@ func TestFetchGeneral(t *testing.T) {
 
 	_ = r.Init(ctx)
 
-	_, err := r.fetchGeneral(ctx, buf)
+	b, err := r.fetchGeneral(ctx, buf)
 	assert.Equal(t, nil, err)
+	assert.Equal(t, port, b[params.ParamsGerritPort])
+
+	r.cfg.Config.Spec.Connect.Hostname = "gerrit1.example.com"
+	r.cfg.Config.Spec.Connect.Name = "gerrit1"
+	r.cfg.Config.Spec.Connect.Ssh.Port = 29419
+
+	b, err = r.fetchGeneral(ctx, buf)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "gerrit1.example.com", b[params.ParamsGerritHost])
+	assert.Equal(t, "gerrit1", b[params.ParamsGerritName])
+	assert.Equal(t, "29419", b[params.ParamsGerritPort])
 }
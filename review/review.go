@@ -0,0 +1,387 @@
+package review
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/build"
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/connect"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+const (
+	// e.g., "build http://localhost:8083/job/build/1/ : succeeded in 1m2s"
+	jobTemplate = `{{ .Job }}{{ with .Url }} {{ . }}{{ end }} : {{ .Status }}{{ with .Duration }} in {{ . }}{{ end }}` +
+		`{{ if .Optional }} (optional){{ end }}`
+
+	startedRetention = 24 * time.Hour
+	voteTimeout      = 30 * time.Second
+)
+
+var (
+	// Severity of status from low to high, the highest one of jobs is voted in summary
+	severity = []string{
+		dispatch.StatusSucceeded,
+		dispatch.StatusAborted,
+		dispatch.StatusUnstable,
+		dispatch.StatusFailed,
+	}
+
+	statuses = []string{
+		dispatch.StatusAborted,
+		dispatch.StatusFailed,
+		dispatch.StatusStarted,
+		dispatch.StatusSucceeded,
+		dispatch.StatusUnstable,
+	}
+)
+
+type Review interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Run(context.Context, *build.Record) error
+}
+
+type Config struct {
+	Build  build.Build
+	Config config.Config
+	Logger hclog.Logger
+	Rests  map[string]connect.Rest
+}
+
+// Data to store fields of one build in job templates
+type Data struct {
+	Duration time.Duration
+	Event    *events.Event
+	Job      string
+	Optional bool
+	Params   map[string]string
+	Status   string
+	Url      string
+}
+
+// Summary to store fields of builds triggered by one event in review message templates
+type Summary struct {
+	Builds []Data
+	Event  *events.Event
+	Params map[string]string
+	Status string
+}
+
+type review struct {
+	cfg       *Config
+	mutex     sync.Mutex
+	optional  map[string]bool
+	started   map[string]time.Time
+	templates map[string]*template.Template
+}
+
+func New(_ context.Context, cfg *Config) Review {
+	return &review{
+		cfg:       cfg,
+		optional:  map[string]bool{},
+		started:   map[string]time.Time{},
+		templates: map[string]*template.Template{},
+	}
+}
+
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+func (r *review) Init(ctx context.Context) error {
+	r.cfg.Logger.Debug("review: Init")
+
+	if err := r.initTemplates(ctx); err != nil {
+		return errors.Wrap(err, "failed to init templates")
+	}
+
+	for _, item := range r.cfg.Config.Spec.Trigger.Jobs {
+		r.optional[item.Name] = item.Optional
+	}
+
+	for name, item := range r.cfg.Rests {
+		if err := item.Init(ctx); err != nil {
+			return errors.Wrap(err, "failed to init rest "+name)
+		}
+	}
+
+	return nil
+}
+
+func (r *review) Deinit(ctx context.Context) error {
+	r.cfg.Logger.Debug("review: Deinit")
+
+	for _, item := range r.cfg.Rests {
+		_ = item.Deinit(ctx)
+	}
+
+	return nil
+}
+
+func (r *review) Run(ctx context.Context, record *build.Record) error {
+	r.cfg.Logger.Debug("review: Run")
+
+	if r.cfg.Config.Spec.Review.Label == "" {
+		return nil
+	}
+
+	req := record.Request
+	if req == nil || req.Event == nil || req.Event.Change.Number <= 0 || req.Event.PatchSet.Number <= 0 {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	records, err := r.records(ctx, record)
+	if err != nil {
+		return errors.Wrap(err, "failed to list builds")
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	status := record.Status
+	if build.Done(status) {
+		status = r.aggregate(records)
+	}
+
+	v := r.vote(status)
+	if v.Message == "" && v.Value == "" {
+		return nil
+	}
+
+	msg, err := r.message(status, req, records)
+	if err != nil {
+		return errors.Wrap(err, "failed to render message")
+	}
+
+	rest, ok := r.cfg.Rests[req.Connect]
+	if !ok {
+		return errors.New("invalid connect " + req.Connect)
+	}
+
+	// Vote is bounded since builds are reported one by one while voting
+	ctx, cancel := context.WithTimeout(ctx, voteTimeout)
+	defer cancel()
+
+	if err := rest.Vote(ctx, req.Event.Change.Number, req.Event.PatchSet.Number, r.cfg.Config.Spec.Review.Label,
+		msg, v.Value); err != nil {
+		return errors.Wrap(err, "failed to vote")
+	}
+
+	r.cfg.Logger.Info("review: Run", "change", req.Event.Change.Number, "patchset", req.Event.PatchSet.Number,
+		"jobs", len(records), "status", status, "value", v.Value)
+
+	return nil
+}
+
+func (r *review) records(ctx context.Context, record *build.Record) ([]*build.Record, error) {
+	if record.Group == "" {
+		return []*build.Record{record}, nil
+	}
+
+	// Vote once for all builds triggered by one event on the change and patchset
+	records, err := r.cfg.Build.List(ctx, &build.Filter{
+		Change:   record.Change,
+		Connect:  record.Connect,
+		Group:    record.Group,
+		Patchset: record.Patchset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Status == dispatch.StatusStarted {
+		if _, ok := r.started[record.Group]; ok {
+			return nil, nil
+		}
+		for _, item := range records {
+			if item.Status == dispatch.StatusQueued {
+				return nil, nil
+			}
+		}
+		r.prune()
+		r.started[record.Group] = time.Now()
+		return records, nil
+	}
+
+	done := map[string]bool{}
+
+	for _, item := range records {
+		if !build.Done(item.Status) {
+			return nil, nil
+		}
+		done[item.Job] = true
+	}
+
+	// Jobs matched by event may be added later, e.g., in longer quiet period
+	if record.Request != nil {
+		for _, item := range record.Request.Jobs {
+			if !done[item] {
+				return nil, nil
+			}
+		}
+	}
+
+	delete(r.started, record.Group)
+
+	return records, nil
+}
+
+// prune to drop groups started but never finished in history retention, whose builds are expired in build store
+func (r *review) prune() {
+	retention := time.Duration(r.cfg.Config.Spec.History.RetentionSeconds) * time.Second
+	if retention <= 0 {
+		retention = startedRetention
+	}
+
+	for key, val := range r.started {
+		if time.Since(val) > retention {
+			delete(r.started, key)
+		}
+	}
+}
+
+func (r *review) initTemplates(_ context.Context) error {
+	var err error
+
+	for _, status := range statuses {
+		if r.templates[status], err = template.New(status).Parse(r.vote(status).Message); err != nil {
+			return errors.Wrap(err, "failed to parse "+status)
+		}
+	}
+
+	for _, job := range r.cfg.Config.Spec.Trigger.Jobs {
+		for _, status := range statuses {
+			text := jobReview(&job.Review, status)
+			if text == "" {
+				text = jobTemplate
+			}
+			name := job.Name + "/" + status
+			if r.templates[name], err = template.New(name).Parse(text); err != nil {
+				return errors.Wrap(err, "failed to parse "+name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *review) vote(status string) config.Vote {
+	buf := r.cfg.Config.Spec.Review
+
+	switch status {
+	case dispatch.StatusAborted:
+		return buf.Aborted
+	case dispatch.StatusFailed:
+		return buf.Failed
+	case dispatch.StatusStarted:
+		return buf.Started
+	case dispatch.StatusSucceeded:
+		return buf.Succeeded
+	case dispatch.StatusUnstable:
+		return buf.Unstable
+	default:
+		return config.Vote{}
+	}
+}
+
+func (r *review) message(status string, req *dispatch.Request, records []*build.Record) (string, error) {
+	s := Summary{
+		Builds: make([]Data, len(records)),
+		Event:  req.Event,
+		Params: req.Params,
+		Status: status,
+	}
+
+	lines := make([]string, len(records))
+
+	for i, item := range records {
+		s.Builds[i] = Data{
+			Duration: item.Duration.Round(time.Second),
+			Job:      item.Job,
+			Optional: r.optional[item.Job],
+			Status:   item.Status,
+			Url:      item.Url,
+		}
+		if item.Request != nil {
+			s.Builds[i].Event = item.Request.Event
+			s.Builds[i].Params = item.Request.Params
+		}
+		buf, err := r.render(item.Job+"/"+item.Status, &s.Builds[i])
+		if err != nil {
+			return "", err
+		}
+		lines[i] = buf
+	}
+
+	head, err := r.render(status, &s)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(head + "\n\n" + strings.Join(lines, "\n")), nil
+}
+
+func (r *review) render(name string, data any) (string, error) {
+	var buf bytes.Buffer
+
+	t, ok := r.templates[name]
+	if !ok {
+		// Jobs out of config use the default template, e.g., the legacy job named after metadata
+		t = template.Must(template.New(name).Parse(jobTemplate))
+	}
+
+	if err := t.Execute(&buf, data); err != nil {
+		return "", errors.Wrap(err, "failed to execute "+name)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func jobReview(cfg *config.JobReview, status string) string {
+	switch status {
+	case dispatch.StatusAborted:
+		return cfg.Aborted
+	case dispatch.StatusFailed:
+		return cfg.Failed
+	case dispatch.StatusStarted:
+		return cfg.Started
+	case dispatch.StatusSucceeded:
+		return cfg.Succeeded
+	case dispatch.StatusUnstable:
+		return cfg.Unstable
+	default:
+		return ""
+	}
+}
+
+func (r *review) aggregate(records []*build.Record) string {
+	buf := 0
+
+	for _, item := range records {
+		// Optional jobs never decide the verdict
+		if r.optional[item.Job] {
+			continue
+		}
+		for i := range severity {
+			if severity[i] == item.Status && i > buf {
+				buf = i
+			}
+		}
+	}
+
+	return severity[buf]
+}
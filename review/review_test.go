@@ -0,0 +1,311 @@
+package review
+
+import (
+	"context"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/build"
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/connect"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
+	"github.com/gerrittrigger/trigger/params"
+)
+
+type restTest struct {
+	connect.Rest
+	change   int
+	count    int
+	deadline time.Time
+	label    string
+	message  string
+	revision int
+	vote     string
+}
+
+func (r *restTest) Init(_ context.Context) error {
+	return nil
+}
+
+func (r *restTest) Vote(ctx context.Context, change, revision int, label, message, vote string) error {
+	r.change = change
+	r.count++
+	r.deadline, _ = ctx.Deadline()
+	r.label = label
+	r.message = message
+	r.revision = revision
+	r.vote = vote
+
+	return nil
+}
+
+func initReview() (*review, *restTest) {
+	rest := &restTest{}
+
+	r := &review{
+		cfg:       DefaultConfig(),
+		optional:  map[string]bool{},
+		started:   map[string]time.Time{},
+		templates: map[string]*template.Template{},
+	}
+
+	r.cfg.Config = config.Config{}
+	r.cfg.Config.Spec.Review = config.Review{
+		Failed:    config.Vote{Message: "Build Failed", Value: "-1"},
+		Label:     "Verified",
+		Started:   config.Vote{Message: "Build Started", Value: "0"},
+		Succeeded: config.Vote{Message: "Build Successful", Value: "+1"},
+		Unstable:  config.Vote{Message: "Build Unstable ({{ len .Builds }} jobs)", Value: "-1"},
+	}
+
+	r.cfg.Config.Spec.Trigger.Jobs = []config.Job{
+		{
+			Name: "build",
+		},
+		{
+			Name:     "doc",
+			Optional: true,
+		},
+		{
+			Name: "lint",
+			Review: config.JobReview{
+				Unstable: `lint {{ index .Params "GERRIT_CHANGE_NUMBER" }} has warnings`,
+			},
+		},
+	}
+
+	r.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "review",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	bc := build.DefaultConfig()
+	bc.Logger = r.cfg.Logger
+
+	r.cfg.Build = build.New(context.Background(), bc)
+	r.cfg.Rests = map[string]connect.Rest{"gerrit": rest}
+
+	_ = r.cfg.Build.Init(context.Background())
+
+	return r, rest
+}
+
+func initRequest(job string) *dispatch.Request {
+	return &dispatch.Request{
+		Connect: "gerrit",
+		Event: &events.Event{
+			Change:   events.Change{Number: 1},
+			PatchSet: events.PatchSet{Number: 2},
+		},
+		Group:  "group",
+		Job:    job,
+		Params: map[string]string{params.ParamsGerritChangeNumber: "1"},
+	}
+}
+
+func TestInit(t *testing.T) {
+	r, _ := initReview()
+	ctx := context.Background()
+
+	err := r.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	r, _ = initReview()
+	r.cfg.Config.Spec.Trigger.Jobs[0].Review.Failed = "{{ invalid"
+
+	err = r.Init(ctx)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestRun(t *testing.T) {
+	r, rest := initReview()
+	ctx := context.Background()
+
+	_ = r.Init(ctx)
+
+	req := initRequest("build")
+	req.Group = ""
+
+	_, _ = r.cfg.Build.Add(ctx, req)
+
+	rec, _ := r.cfg.Build.Update(ctx, &dispatch.Result{Request: req, Status: dispatch.StatusStarted, Url: "http://localhost/1/"})
+
+	err := r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, rest.change)
+	assert.Equal(t, 2, rest.revision)
+	assert.Equal(t, "Verified", rest.label)
+	assert.Equal(t, "0", rest.vote)
+	assert.Equal(t, "Build Started\n\nbuild http://localhost/1/ : started", rest.message)
+	assert.Equal(t, false, rest.deadline.IsZero())
+	assert.Less(t, time.Until(rest.deadline), voteTimeout+time.Second)
+
+	rec, _ = r.cfg.Build.Update(ctx, &dispatch.Result{Request: req, Status: dispatch.StatusSucceeded})
+	rec.Duration = 62 * time.Second
+
+	err = r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "+1", rest.vote)
+	assert.Equal(t, "Build Successful\n\nbuild http://localhost/1/ : succeeded in 1m2s", rest.message)
+
+	rest.count = 0
+	rec.Status = dispatch.StatusAborted
+
+	err = r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, rest.count)
+
+	rec.Request.Connect = "invalid"
+	rec.Status = dispatch.StatusStarted
+
+	err = r.Run(ctx, rec)
+	assert.NotEqual(t, nil, err)
+
+	rec.Request.Event = &events.Event{}
+
+	err = r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+}
+
+func TestSummary(t *testing.T) {
+	r, rest := initReview()
+	ctx := context.Background()
+
+	_ = r.Init(ctx)
+
+	build1 := initRequest("build")
+	lint := initRequest("lint")
+
+	_, _ = r.cfg.Build.Add(ctx, build1)
+	_, _ = r.cfg.Build.Add(ctx, lint)
+
+	rec, _ := r.cfg.Build.Update(ctx, &dispatch.Result{Request: build1, Status: dispatch.StatusSucceeded})
+
+	err := r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, rest.count)
+
+	rec, _ = r.cfg.Build.Update(ctx, &dispatch.Result{Request: lint, Status: dispatch.StatusUnstable})
+
+	err = r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, rest.count)
+	assert.Equal(t, "-1", rest.vote)
+	assert.Equal(t, "Build Unstable (2 jobs)\n\nbuild : succeeded\nlint 1 has warnings", rest.message)
+}
+
+func TestStarted(t *testing.T) {
+	r, rest := initReview()
+	ctx := context.Background()
+
+	_ = r.Init(ctx)
+
+	build1 := initRequest("build")
+	lint := initRequest("lint")
+
+	_, _ = r.cfg.Build.Add(ctx, build1)
+	_, _ = r.cfg.Build.Add(ctx, lint)
+
+	rec, _ := r.cfg.Build.Update(ctx, &dispatch.Result{Request: build1, Status: dispatch.StatusStarted})
+
+	err := r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, rest.count)
+
+	rec, _ = r.cfg.Build.Update(ctx, &dispatch.Result{Request: lint, Status: dispatch.StatusStarted})
+
+	err = r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, rest.count)
+	assert.Equal(t, "Build Started\n\nbuild : started\nlint : started", rest.message)
+
+	rec, _ = r.cfg.Build.Update(ctx, &dispatch.Result{Request: lint, Status: dispatch.StatusStarted})
+
+	err = r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, rest.count)
+
+	// Group never finished is dropped after retention
+	r.started["group"] = time.Now().Add(-2 * startedRetention)
+	r.prune()
+
+	_, ok := r.started["group"]
+	assert.Equal(t, false, ok)
+}
+
+func TestPartial(t *testing.T) {
+	r, rest := initReview()
+	ctx := context.Background()
+
+	_ = r.Init(ctx)
+
+	build1 := initRequest("build")
+	build1.Jobs = []string{"build", "lint"}
+
+	lint := initRequest("lint")
+	lint.Jobs = build1.Jobs
+
+	_, _ = r.cfg.Build.Add(ctx, build1)
+
+	// Lint in longer quiet period is not added yet
+	rec, _ := r.cfg.Build.Update(ctx, &dispatch.Result{Request: build1, Status: dispatch.StatusFailed})
+
+	err := r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, rest.count)
+
+	_, _ = r.cfg.Build.Add(ctx, lint)
+
+	rec, _ = r.cfg.Build.Update(ctx, &dispatch.Result{Request: lint, Status: dispatch.StatusSucceeded})
+
+	err = r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, rest.count)
+	assert.Equal(t, "-1", rest.vote)
+	assert.Equal(t, "Build Failed\n\nbuild : failed\nlint : succeeded", rest.message)
+}
+
+func TestOptional(t *testing.T) {
+	r, rest := initReview()
+	ctx := context.Background()
+
+	_ = r.Init(ctx)
+
+	build1 := initRequest("build")
+	doc := initRequest("doc")
+
+	_, _ = r.cfg.Build.Add(ctx, build1)
+	_, _ = r.cfg.Build.Add(ctx, doc)
+
+	rec, _ := r.cfg.Build.Update(ctx, &dispatch.Result{Request: doc, Status: dispatch.StatusFailed})
+
+	_ = r.Run(ctx, rec)
+
+	rec, _ = r.cfg.Build.Update(ctx, &dispatch.Result{Request: build1, Status: dispatch.StatusSucceeded})
+
+	err := r.Run(ctx, rec)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, rest.count)
+	assert.Equal(t, "+1", rest.vote)
+	assert.Equal(t, "Build Successful\n\nbuild : succeeded\ndoc : failed (optional)", rest.message)
+}
+
+func TestAggregate(t *testing.T) {
+	r, _ := initReview()
+
+	_ = r.Init(context.Background())
+
+	buf := []*build.Record{{Status: dispatch.StatusSucceeded}, {Status: dispatch.StatusAborted}}
+	assert.Equal(t, dispatch.StatusAborted, r.aggregate(buf))
+
+	buf = append(buf, &build.Record{Status: dispatch.StatusFailed}, &build.Record{Status: dispatch.StatusUnstable})
+	assert.Equal(t, dispatch.StatusFailed, r.aggregate(buf))
+
+	buf = []*build.Record{{Job: "build", Status: dispatch.StatusSucceeded}, {Job: "doc", Status: dispatch.StatusFailed}}
+	assert.Equal(t, dispatch.StatusSucceeded, r.aggregate(buf))
+}
@@ -0,0 +1,393 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/build"
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/queue"
+	"github.com/gerrittrigger/trigger/watchdog"
+	"github.com/gerrittrigger/trigger/worker"
+)
+
+const (
+	pathBuild      = "/api/v1/builds/{id}"
+	pathBuilds     = "/api/v1/builds"
+	pathEvents     = "/api/v1/events"
+	pathHealth     = "/api/v1/health"
+	pathPause      = "/api/v1/queue/pause"
+	pathPrioritize = "/api/v1/queue/events/{id}/prioritize"
+	pathQueue      = "/api/v1/queue"
+	pathQueueEvent = "/api/v1/queue/events/{id}"
+	pathResume     = "/api/v1/queue/resume"
+	pathWorkers    = "/api/v1/workers"
+
+	headerTimeout = 10 * time.Second
+	maxBody       = 1024 * 1024
+	tokenPrefix   = "Bearer "
+)
+
+type Server interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+}
+
+type Config struct {
+	Build     build.Build
+	Config    config.Config
+	Logger    hclog.Logger
+	Queues    map[string]queue.Queue
+	Result    chan *dispatch.Result
+	Watchdogs map[string]watchdog.Watchdog
+	Workers   map[string]worker.Worker
+}
+
+// callback to store build status reported by CI systems
+type callback struct {
+	Status string `json:"status"`
+	Url    string `json:"url"`
+}
+
+type server struct {
+	cfg    *Config
+	server *http.Server
+}
+
+func New(_ context.Context, cfg *Config) Server {
+	return &server{
+		cfg: cfg,
+	}
+}
+
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+func (s *server) Init(_ context.Context) error {
+	s.cfg.Logger.Debug("server: Init")
+
+	if s.cfg.Config.Spec.Server.Addr == "" {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", s.cfg.Config.Spec.Server.Addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+
+	s.server = &http.Server{
+		Handler:           s.handler(),
+		ReadHeaderTimeout: headerTimeout,
+	}
+
+	go func() {
+		if err := s.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.cfg.Logger.Error("server: Init", "error", err.Error())
+		}
+	}()
+
+	s.cfg.Logger.Info("server: Init", "addr", l.Addr().String())
+
+	return nil
+}
+
+func (s *server) Deinit(ctx context.Context) error {
+	s.cfg.Logger.Debug("server: Deinit")
+
+	if s.server == nil {
+		return nil
+	}
+
+	return s.server.Shutdown(ctx)
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+pathBuilds, s.auth(s.listBuilds))
+	mux.HandleFunc("GET "+pathBuild, s.auth(s.getBuild))
+	mux.HandleFunc("POST "+pathBuild, s.admin(s.postBuild))
+	mux.HandleFunc("POST "+pathEvents, s.admin(s.postEvent))
+	mux.HandleFunc("GET "+pathHealth, s.auth(s.getHealth))
+	mux.HandleFunc("GET "+pathWorkers, s.auth(s.listWorkers))
+	mux.HandleFunc("GET "+pathQueue, s.auth(s.listQueue))
+	mux.HandleFunc("DELETE "+pathQueueEvent, s.admin(s.removeEvent))
+	mux.HandleFunc("POST "+pathPrioritize, s.admin(s.prioritizeEvent))
+	mux.HandleFunc("POST "+pathPause, s.admin(s.pauseQueue))
+	mux.HandleFunc("POST "+pathResume, s.admin(s.resumeQueue))
+
+	return mux
+}
+
+func (s *server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := s.cfg.Config.Spec.Server.Token
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(tokenPrefix+token)) != 1 {
+			s.error(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// admin to require token for endpoints changing builds and events, since they are open to any address
+func (s *server) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.Config.Spec.Server.Token == "" {
+			s.error(w, http.StatusForbidden, "token required")
+			return
+		}
+		s.auth(next)(w, r)
+	}
+}
+
+func (s *server) listBuilds(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	f := build.Filter{
+		Connect: q.Get("connect"),
+		Job:     q.Get("job"),
+	}
+
+	var err error
+
+	if v := q.Get("change"); v != "" {
+		if f.Change, err = strconv.Atoi(v); err != nil {
+			s.error(w, http.StatusBadRequest, "invalid change")
+			return
+		}
+	}
+
+	if v := q.Get("patchset"); v != "" {
+		if f.Patchset, err = strconv.Atoi(v); err != nil {
+			s.error(w, http.StatusBadRequest, "invalid patchset")
+			return
+		}
+	}
+
+	buf, err := s.cfg.Build.List(r.Context(), &f)
+	if err != nil {
+		s.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.write(w, http.StatusOK, buf)
+}
+
+func (s *server) getBuild(w http.ResponseWriter, r *http.Request) {
+	b, err := s.cfg.Build.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.error(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.write(w, http.StatusOK, b)
+}
+
+func (s *server) postBuild(w http.ResponseWriter, r *http.Request) {
+	var buf callback
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&buf); err != nil {
+		s.error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	switch buf.Status {
+	case dispatch.StatusAborted, dispatch.StatusFailed, dispatch.StatusStarted, dispatch.StatusSucceeded, dispatch.StatusUnstable:
+	default:
+		s.error(w, http.StatusBadRequest, "invalid status "+buf.Status)
+		return
+	}
+
+	b, err := s.cfg.Build.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.error(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if build.Done(b.Status) {
+		s.error(w, http.StatusConflict, "build is "+b.Status)
+		return
+	}
+
+	s.cfg.Logger.Debug("server: postBuild", "id", b.Id, "status", buf.Status, "url", buf.Url)
+
+	select {
+	case s.cfg.Result <- &dispatch.Result{Dispatch: b.Dispatch, Request: b.Request, Status: buf.Status, Url: buf.Url}:
+	case <-r.Context().Done():
+		s.error(w, http.StatusServiceUnavailable, "request cancelled")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// postEvent to inject one raw event into the queue of connect, e.g., event re-injected from dead letter
+func (s *server) postEvent(w http.ResponseWriter, r *http.Request) {
+	q, ok := s.cfg.Queues[r.URL.Query().Get("connect")]
+	if !ok {
+		s.error(w, http.StatusNotFound, "invalid connect")
+		return
+	}
+
+	buf, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil || !json.Valid(buf) {
+		s.error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if err := q.Put(r.Context(), string(bytes.TrimSpace(buf))); err != nil {
+		s.error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *server) listWorkers(w http.ResponseWriter, r *http.Request) {
+	buf := map[string]worker.Stats{}
+
+	for name, item := range s.cfg.Workers {
+		buf[name] = item.Stats(r.Context())
+	}
+
+	s.write(w, http.StatusOK, buf)
+}
+
+// getHealth to report connection health of each connect checked by watchdog, with 503 if any is unhealthy
+func (s *server) getHealth(w http.ResponseWriter, r *http.Request) {
+	buf := map[string]watchdog.Health{}
+	code := http.StatusOK
+
+	for name, item := range s.cfg.Watchdogs {
+		buf[name] = item.Health(r.Context())
+		if !buf[name].Healthy {
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	s.write(w, code, buf)
+}
+
+func (s *server) listQueue(w http.ResponseWriter, r *http.Request) {
+	queues, ok := s.queues(r)
+	if !ok {
+		s.error(w, http.StatusNotFound, "invalid connect")
+		return
+	}
+
+	buf := map[string]*queue.Snapshot{}
+
+	for name, item := range queues {
+		b, err := item.List(r.Context())
+		if err != nil {
+			s.error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		buf[name] = b
+	}
+
+	s.write(w, http.StatusOK, buf)
+}
+
+func (s *server) removeEvent(w http.ResponseWriter, r *http.Request) {
+	s.updateEvent(w, r, func(q queue.Queue, id string) error {
+		return q.Remove(r.Context(), id)
+	})
+}
+
+func (s *server) prioritizeEvent(w http.ResponseWriter, r *http.Request) {
+	s.updateEvent(w, r, func(q queue.Queue, id string) error {
+		return q.Prioritize(r.Context(), id)
+	})
+}
+
+// updateEvent to update one pending event in the queue of connect
+func (s *server) updateEvent(w http.ResponseWriter, r *http.Request, update func(queue.Queue, string) error) {
+	q, ok := s.cfg.Queues[r.URL.Query().Get("connect")]
+	if !ok {
+		s.error(w, http.StatusNotFound, "invalid connect")
+		return
+	}
+
+	if err := update(q, r.PathValue("id")); err != nil {
+		if errors.Is(err, queue.ErrNotFound) {
+			s.error(w, http.StatusNotFound, "invalid id")
+		} else {
+			s.error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) pauseQueue(w http.ResponseWriter, r *http.Request) {
+	s.updateQueue(w, r, func(q queue.Queue) error {
+		return q.Pause(r.Context())
+	})
+}
+
+func (s *server) resumeQueue(w http.ResponseWriter, r *http.Request) {
+	s.updateQueue(w, r, func(q queue.Queue) error {
+		return q.Resume(r.Context())
+	})
+}
+
+// updateQueue to update the queue of connect, or queues of all connects if connect is not specified
+func (s *server) updateQueue(w http.ResponseWriter, r *http.Request, update func(queue.Queue) error) {
+	queues, ok := s.queues(r)
+	if !ok {
+		s.error(w, http.StatusNotFound, "invalid connect")
+		return
+	}
+
+	for _, item := range queues {
+		if err := update(item); err != nil {
+			s.error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) queues(r *http.Request) (map[string]queue.Queue, bool) {
+	if !r.URL.Query().Has("connect") {
+		return s.cfg.Queues, true
+	}
+
+	name := r.URL.Query().Get("connect")
+
+	q, ok := s.cfg.Queues[name]
+	if !ok {
+		return nil, false
+	}
+
+	return map[string]queue.Queue{name: q}, true
+}
+
+func (s *server) error(w http.ResponseWriter, code int, msg string) {
+	s.write(w, code, map[string]string{"error": msg})
+}
+
+func (s *server) write(w http.ResponseWriter, code int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		s.cfg.Logger.Error("server: write", "error", err.Error())
+	}
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/build"
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
+	"github.com/gerrittrigger/trigger/queue"
+	"github.com/gerrittrigger/trigger/watchdog"
+	"github.com/gerrittrigger/trigger/worker"
+)
+
+func initServer() server {
+	s := server{
+		cfg: DefaultConfig(),
+	}
+
+	s.cfg.Config = config.Config{}
+	s.cfg.Config.Spec.Server.Token = "token"
+
+	s.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "server",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	bc := build.DefaultConfig()
+	bc.Logger = s.cfg.Logger
+
+	s.cfg.Build = build.New(context.Background(), bc)
+	s.cfg.Result = make(chan *dispatch.Result, 1)
+
+	_ = s.cfg.Build.Init(context.Background())
+
+	return s
+}
+
+func send(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+
+	rsp := httptest.NewRecorder()
+	h.ServeHTTP(rsp, req)
+
+	return rsp
+}
+
+func TestInit(t *testing.T) {
+	s := initServer()
+	ctx := context.Background()
+
+	err := s.Init(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, (*http.Server)(nil), s.server)
+
+	s.cfg.Config.Spec.Server.Addr = "127.0.0.1:0"
+
+	err = s.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	err = s.Deinit(ctx)
+	assert.Equal(t, nil, err)
+}
+
+func TestAuth(t *testing.T) {
+	s := initServer()
+	h := s.handler()
+
+	req := httptest.NewRequest(http.MethodGet, pathBuilds, http.NoBody)
+	rsp := httptest.NewRecorder()
+	h.ServeHTTP(rsp, req)
+	assert.Equal(t, http.StatusUnauthorized, rsp.Code)
+
+	rsp = send(h, http.MethodGet, pathBuilds, "")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	// Endpoints changing builds and events are turned off without token
+	s.cfg.Config.Spec.Server.Token = ""
+
+	rsp = send(h, http.MethodGet, pathBuilds, "")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+
+	rsp = send(h, http.MethodPost, pathPause, "")
+	assert.Equal(t, http.StatusForbidden, rsp.Code)
+
+	rsp = send(h, http.MethodPost, pathEvents, "{}")
+	assert.Equal(t, http.StatusForbidden, rsp.Code)
+}
+
+func TestBuild(t *testing.T) {
+	s := initServer()
+	h := s.handler()
+	ctx := context.Background()
+
+	req := &dispatch.Request{
+		Event: &events.Event{
+			Change:   events.Change{Number: 1},
+			PatchSet: events.PatchSet{Number: 2},
+		},
+		Job: "build",
+	}
+
+	r, _ := s.cfg.Build.Add(ctx, req)
+
+	rsp := send(h, http.MethodGet, "/api/v1/builds/"+r.Id, "")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+
+	rsp = send(h, http.MethodGet, "/api/v1/builds/invalid", "")
+	assert.Equal(t, http.StatusNotFound, rsp.Code)
+
+	rsp = send(h, http.MethodGet, pathBuilds+"?change=1&patchset=2", "")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+
+	var buf []build.Record
+
+	_ = json.Unmarshal(rsp.Body.Bytes(), &buf)
+	assert.Equal(t, 1, len(buf))
+	assert.Equal(t, r.Id, buf[0].Id)
+
+	rsp = send(h, http.MethodGet, pathBuilds+"?change=invalid", "")
+	assert.Equal(t, http.StatusBadRequest, rsp.Code)
+
+	rsp = send(h, http.MethodPost, "/api/v1/builds/"+r.Id, `{"status":"invalid"}`)
+	assert.Equal(t, http.StatusBadRequest, rsp.Code)
+
+	rsp = send(h, http.MethodPost, "/api/v1/builds/"+r.Id, `{"status":"started","url":"http://localhost/1/"}`)
+	assert.Equal(t, http.StatusAccepted, rsp.Code)
+
+	res := <-s.cfg.Result
+	assert.Equal(t, req, res.Request)
+	assert.Equal(t, dispatch.StatusStarted, res.Status)
+	assert.Equal(t, "http://localhost/1/", res.Url)
+
+	_, _ = s.cfg.Build.Update(ctx, &dispatch.Result{Request: req, Status: dispatch.StatusSucceeded})
+
+	rsp = send(h, http.MethodPost, "/api/v1/builds/"+r.Id, `{"status":"failed"}`)
+	assert.Equal(t, http.StatusConflict, rsp.Code)
+}
+
+func TestWorkers(t *testing.T) {
+	s := initServer()
+	ctx := context.Background()
+
+	wc := worker.DefaultConfig()
+	wc.Logger = s.cfg.Logger
+
+	wk := worker.New(ctx, wc)
+	_ = wk.Init(ctx)
+
+	defer func() {
+		_ = wk.Deinit(ctx)
+	}()
+
+	s.cfg.Workers = map[string]worker.Worker{"gerrit": wk}
+
+	rsp := send(s.handler(), http.MethodGet, pathWorkers, "")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+
+	var buf map[string]worker.Stats
+
+	_ = json.Unmarshal(rsp.Body.Bytes(), &buf)
+	assert.Equal(t, 1, buf["gerrit"].Count)
+	assert.Equal(t, 1, len(buf["gerrit"].Workers))
+}
+
+func TestEvents(t *testing.T) {
+	s := initServer()
+	ctx := context.Background()
+
+	qc := queue.DefaultConfig()
+	qc.Logger = s.cfg.Logger
+
+	q := queue.New(ctx, qc)
+	_ = q.Init(ctx)
+
+	s.cfg.Queues = map[string]queue.Queue{"gerrit": q}
+
+	h := s.handler()
+
+	rsp := send(h, http.MethodPost, pathEvents+"?connect=invalid", `{"type":"patchset-created"}`)
+	assert.Equal(t, http.StatusNotFound, rsp.Code)
+
+	rsp = send(h, http.MethodPost, pathEvents+"?connect=gerrit", `invalid`)
+	assert.Equal(t, http.StatusBadRequest, rsp.Code)
+
+	r, _ := q.Get(ctx)
+
+	go func() {
+		_ = send(h, http.MethodPost, pathEvents+"?connect=gerrit", `{"type":"patchset-created"}`)
+	}()
+
+	assert.Equal(t, `{"type":"patchset-created"}`, <-r)
+}
+
+func TestQueue(t *testing.T) {
+	s := initServer()
+	ctx := context.Background()
+
+	qc := queue.DefaultConfig()
+	qc.Logger = s.cfg.Logger
+
+	q := queue.New(ctx, qc)
+	_ = q.Init(ctx)
+
+	defer func(q queue.Queue, ctx context.Context) {
+		_ = q.Close(ctx)
+	}(q, ctx)
+
+	s.cfg.Queues = map[string]queue.Queue{"gerrit": q}
+
+	h := s.handler()
+
+	rsp := send(h, http.MethodPost, pathPause+"?connect=invalid", "")
+	assert.Equal(t, http.StatusNotFound, rsp.Code)
+
+	rsp = send(h, http.MethodPost, pathPause, "")
+	assert.Equal(t, http.StatusNoContent, rsp.Code)
+
+	_ = q.Put(ctx, `{"type":"patchset-created"}`)
+	_ = q.Put(ctx, `{"type":"comment-added"}`)
+
+	rsp = send(h, http.MethodGet, pathQueue+"?connect=gerrit", "")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+
+	var buf map[string]queue.Snapshot
+
+	_ = json.Unmarshal(rsp.Body.Bytes(), &buf)
+	assert.Equal(t, true, buf["gerrit"].Paused)
+	assert.Equal(t, 2, len(buf["gerrit"].Pending))
+
+	id := buf["gerrit"].Pending[1].Id
+
+	rsp = send(h, http.MethodPost, "/api/v1/queue/events/invalid/prioritize?connect=gerrit", "")
+	assert.Equal(t, http.StatusNotFound, rsp.Code)
+
+	rsp = send(h, http.MethodPost, "/api/v1/queue/events/"+id+"/prioritize?connect=gerrit", "")
+	assert.Equal(t, http.StatusNoContent, rsp.Code)
+
+	rsp = send(h, http.MethodDelete, "/api/v1/queue/events/"+buf["gerrit"].Pending[0].Id+"?connect=gerrit", "")
+	assert.Equal(t, http.StatusNoContent, rsp.Code)
+
+	rsp = send(h, http.MethodPost, pathResume+"?connect=gerrit", "")
+	assert.Equal(t, http.StatusNoContent, rsp.Code)
+
+	r, _ := q.Get(ctx)
+	assert.Equal(t, `{"type":"comment-added"}`, <-r)
+
+	rsp = send(h, http.MethodGet, pathQueue, "")
+
+	buf = nil
+	_ = json.Unmarshal(rsp.Body.Bytes(), &buf)
+	assert.Equal(t, false, buf["gerrit"].Paused)
+	assert.Equal(t, 0, len(buf["gerrit"].Pending))
+	assert.Equal(t, 1, len(buf["gerrit"].Inflight))
+}
+
+func TestHealth(t *testing.T) {
+	s := initServer()
+	ctx := context.Background()
+
+	wc := watchdog.DefaultConfig()
+	wc.Logger = s.cfg.Logger
+
+	wd := watchdog.New(ctx, wc)
+
+	s.cfg.Watchdogs = map[string]watchdog.Watchdog{"gerrit": wd}
+
+	rsp := send(s.handler(), http.MethodGet, pathHealth, "")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+
+	var buf map[string]watchdog.Health
+
+	_ = json.Unmarshal(rsp.Body.Bytes(), &buf)
+	assert.Equal(t, true, buf["gerrit"].Healthy)
+}
@@ -0,0 +1,172 @@
+package supersede
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/build"
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+const (
+	retention = 24 * time.Hour
+)
+
+type Supersede interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Run(context.Context, *dispatch.Request) ([]*build.Record, error)
+	Stale(context.Context, *dispatch.Request) bool
+}
+
+type Config struct {
+	Build  build.Build
+	Config config.Config
+	Logger hclog.Logger
+}
+
+// change to store the latest state of one change
+type change struct {
+	closed   bool
+	patchset int
+	updated  time.Time
+}
+
+type supersede struct {
+	cfg       *Config
+	changes   map[string]*change
+	jobs      map[string]bool
+	mutex     sync.Mutex
+	retention time.Duration
+}
+
+func New(_ context.Context, cfg *Config) Supersede {
+	return &supersede{
+		cfg:       cfg,
+		changes:   map[string]*change{},
+		jobs:      map[string]bool{},
+		retention: time.Duration(cfg.Config.Spec.History.RetentionSeconds) * time.Second,
+	}
+}
+
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+func (s *supersede) Init(_ context.Context) error {
+	s.cfg.Logger.Debug("supersede: Init")
+
+	if s.retention <= 0 {
+		s.retention = retention
+	}
+
+	for _, item := range s.cfg.Config.Spec.Trigger.Jobs {
+		s.jobs[item.Name] = item.Cancel
+	}
+
+	return nil
+}
+
+func (s *supersede) Deinit(_ context.Context) error {
+	s.cfg.Logger.Debug("supersede: Deinit")
+
+	return nil
+}
+
+// Run to track the change of event and return active builds superseded by it
+func (s *supersede) Run(ctx context.Context, req *dispatch.Request) ([]*build.Record, error) {
+	s.cfg.Logger.Debug("supersede: Run")
+
+	if req.Event == nil || req.Event.Change.Number <= 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+
+	s.mutex.Lock()
+
+	s.prune(now)
+
+	key := s.key(req.Connect, req.Event.Change.Number)
+
+	c, ok := s.changes[key]
+	if !ok {
+		c = &change{}
+		s.changes[key] = c
+	}
+
+	c.updated = now
+
+	switch req.Event.Type {
+	case events.EventsChangeAbandoned, events.EventsChangeDeleted:
+		c.closed = true
+	case events.EventsChangeRestored:
+		c.closed = false
+	case events.EventsPatchsetCreated:
+		if req.Event.PatchSet.Number > c.patchset {
+			c.patchset = req.Event.PatchSet.Number
+		}
+	}
+
+	closed, patchset := c.closed, c.patchset
+
+	s.mutex.Unlock()
+
+	records, err := s.cfg.Build.List(ctx, &build.Filter{Change: req.Event.Change.Number, Connect: req.Connect})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list builds")
+	}
+
+	var buf []*build.Record
+
+	for _, item := range records {
+		if build.Done(item.Status) || !s.jobs[item.Job] {
+			continue
+		}
+		if closed || item.Patchset < patchset {
+			buf = append(buf, item)
+		}
+	}
+
+	return buf, nil
+}
+
+// Stale to check if request is superseded before dispatching
+func (s *supersede) Stale(_ context.Context, req *dispatch.Request) bool {
+	if req.Event == nil || req.Event.Change.Number <= 0 || !s.jobs[req.Job] {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, ok := s.changes[s.key(req.Connect, req.Event.Change.Number)]
+	if !ok {
+		return false
+	}
+
+	if c.closed {
+		return req.Event.Type != events.EventsChangeAbandoned && req.Event.Type != events.EventsChangeDeleted
+	}
+
+	return req.Event.PatchSet.Number < c.patchset
+}
+
+func (s *supersede) prune(now time.Time) {
+	for key, item := range s.changes {
+		if now.Sub(item.updated) > s.retention {
+			delete(s.changes, key)
+		}
+	}
+}
+
+func (s *supersede) key(connect string, number int) string {
+	return connect + "/" + strconv.Itoa(number)
+}
@@ -0,0 +1,110 @@
+package supersede
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/build"
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+func initSupersede() *supersede {
+	s := &supersede{
+		cfg:     DefaultConfig(),
+		changes: map[string]*change{},
+		jobs:    map[string]bool{},
+	}
+
+	s.cfg.Config = config.Config{}
+	s.cfg.Config.Spec.Trigger.Jobs = []config.Job{
+		{Cancel: true, Name: "build"},
+		{Name: "notify"},
+	}
+
+	s.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "supersede",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	bc := build.DefaultConfig()
+	bc.Logger = s.cfg.Logger
+
+	s.cfg.Build = build.New(context.Background(), bc)
+
+	_ = s.cfg.Build.Init(context.Background())
+
+	return s
+}
+
+func initRequest(job, _type string, patchset int) *dispatch.Request {
+	return &dispatch.Request{
+		Connect: "gerrit",
+		Event: &events.Event{
+			Change:   events.Change{Number: 1},
+			PatchSet: events.PatchSet{Number: patchset},
+			Type:     _type,
+		},
+		Job: job,
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := initSupersede()
+	ctx := context.Background()
+
+	_ = s.Init(ctx)
+
+	build1 := initRequest("build", events.EventsPatchsetCreated, 1)
+	notify := initRequest("notify", events.EventsPatchsetCreated, 1)
+
+	_, _ = s.cfg.Build.Add(ctx, build1)
+	_, _ = s.cfg.Build.Add(ctx, notify)
+
+	buf, err := s.Run(ctx, initRequest("", events.EventsPatchsetCreated, 1))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(buf))
+
+	buf, err = s.Run(ctx, initRequest("", events.EventsPatchsetCreated, 2))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(buf))
+	assert.Equal(t, build1.Id, buf[0].Id)
+
+	_, _ = s.cfg.Build.Update(ctx, &dispatch.Result{Request: build1, Status: dispatch.StatusAborted})
+
+	build2 := initRequest("build", events.EventsPatchsetCreated, 2)
+	_, _ = s.cfg.Build.Add(ctx, build2)
+
+	buf, err = s.Run(ctx, initRequest("", events.EventsChangeAbandoned, 2))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(buf))
+	assert.Equal(t, build2.Id, buf[0].Id)
+}
+
+func TestStale(t *testing.T) {
+	s := initSupersede()
+	ctx := context.Background()
+
+	_ = s.Init(ctx)
+
+	assert.Equal(t, false, s.Stale(ctx, initRequest("build", events.EventsPatchsetCreated, 1)))
+
+	_, _ = s.Run(ctx, initRequest("", events.EventsPatchsetCreated, 2))
+
+	assert.Equal(t, true, s.Stale(ctx, initRequest("build", events.EventsPatchsetCreated, 1)))
+	assert.Equal(t, false, s.Stale(ctx, initRequest("build", events.EventsPatchsetCreated, 2)))
+	assert.Equal(t, false, s.Stale(ctx, initRequest("notify", events.EventsPatchsetCreated, 1)))
+
+	_, _ = s.Run(ctx, initRequest("", events.EventsChangeAbandoned, 2))
+
+	assert.Equal(t, true, s.Stale(ctx, initRequest("build", events.EventsCommentAdded, 2)))
+	assert.Equal(t, false, s.Stale(ctx, initRequest("build", events.EventsChangeAbandoned, 2)))
+
+	_, _ = s.Run(ctx, initRequest("", events.EventsChangeRestored, 2))
+
+	assert.Equal(t, false, s.Stale(ctx, initRequest("build", events.EventsCommentAdded, 2)))
+}
@@ -0,0 +1,106 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/events"
+	"github.com/gerrittrigger/trigger/playback"
+)
+
+// cursor to store playback cursor of events done in workers, and never move it past events still in flight
+type cursor struct {
+	playback playback.Playback
+	finished map[string]int64
+	inflight map[string]*flight
+	mutex    sync.Mutex
+	stored   int64
+}
+
+type flight struct {
+	count   int
+	created int64
+}
+
+func newCursor(p playback.Playback) *cursor {
+	return &cursor{
+		playback: p,
+		finished: map[string]int64{},
+		inflight: map[string]*flight{},
+	}
+}
+
+// track to mark event in flight before processed in workers
+func (c *cursor) track(data string) {
+	e := events.Event{}
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if f, ok := c.inflight[data]; ok {
+		f.count++
+		return
+	}
+
+	c.inflight[data] = &flight{count: 1, created: e.EventCreatedOn}
+}
+
+// done to mark event processed, and store the newest event processed older than all events in flight
+func (c *cursor) done(ctx context.Context, data string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	f, ok := c.inflight[data]
+	if !ok {
+		return nil
+	}
+
+	if f.count--; f.count == 0 {
+		delete(c.inflight, data)
+	}
+
+	c.finished[data] = f.created
+
+	oldest := int64(-1)
+
+	for _, item := range c.inflight {
+		if oldest < 0 || item.created < oldest {
+			oldest = item.created
+		}
+	}
+
+	// Events of the same second as the oldest in flight are kept since playback starts from the next second
+	next := ""
+	created := c.stored
+
+	for key, val := range c.finished {
+		if oldest >= 0 && val >= oldest {
+			continue
+		}
+		if val > created {
+			next = key
+			created = val
+		}
+	}
+
+	if next != "" {
+		if err := c.playback.Store(ctx, next); err != nil {
+			return errors.Wrap(err, "failed to store playback")
+		}
+		c.stored = created
+	}
+
+	for key, val := range c.finished {
+		if val <= c.stored {
+			delete(c.finished, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package trigger
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/playback"
+)
+
+// testStore to record events stored as playback cursor
+type testStore struct {
+	playback.Playback
+	mutex  sync.Mutex
+	stored []string
+}
+
+func (p *testStore) Store(_ context.Context, event string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.stored = append(p.stored, event)
+
+	return nil
+}
+
+func TestCursor(t *testing.T) {
+	p := &testStore{}
+	c := newCursor(p)
+	ctx := context.Background()
+
+	first := `{"type":"ref-updated","eventCreatedOn":100}`
+	second := `{"type":"patchset-created","eventCreatedOn":110}`
+	third := `{"type":"comment-added","eventCreatedOn":120}`
+
+	c.track(first)
+	c.track(second)
+	c.track(third)
+
+	// Cursor is not moved past events still in flight
+	err := c.done(ctx, third)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(p.stored))
+
+	_ = c.done(ctx, first)
+	assert.Equal(t, []string{first}, p.stored)
+
+	_ = c.done(ctx, second)
+	assert.Equal(t, []string{first, third}, p.stored)
+
+	// Cursor is never moved backwards
+	c.track(first)
+	_ = c.done(ctx, first)
+	assert.Equal(t, []string{first, third}, p.stored)
+
+	// Invalid event is not stored
+	c.track("invalid")
+	_ = c.done(ctx, "invalid")
+	assert.Equal(t, []string{first, third}, p.stored)
+}
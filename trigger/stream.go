@@ -0,0 +1,89 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/gerrittrigger/trigger/events"
+	"github.com/gerrittrigger/trigger/queue"
+)
+
+// stream to track events received from stream events, and skip them in events replayed after reconnection
+type stream struct {
+	queue.Queue
+	cutoff    int64
+	last      int64
+	mutex     sync.Mutex
+	replaying bool
+	seen      map[string]bool
+}
+
+func newStream(q queue.Queue) *stream {
+	return &stream{
+		Queue: q,
+		seen:  map[string]bool{},
+	}
+}
+
+func (s *stream) Put(ctx context.Context, data string) error {
+	e := events.Event{}
+	_ = json.Unmarshal([]byte(data), &e)
+
+	s.mutex.Lock()
+
+	// Events of the last second are kept to skip replayed ones, and all events are kept while replaying
+	if e.EventCreatedOn > s.last {
+		s.last = e.EventCreatedOn
+		if !s.replaying {
+			s.seen = map[string]bool{}
+		}
+	}
+
+	s.seen[data] = true
+
+	s.mutex.Unlock()
+
+	return s.Queue.Put(ctx, data)
+}
+
+// begin to mark the stream terminated, and the cutoff is kept until replayed
+func (s *stream) begin() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.replaying {
+		return
+	}
+
+	s.cutoff = s.last
+	s.replaying = true
+}
+
+// end to mark events replayed
+func (s *stream) end() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.replaying = false
+}
+
+func (s *stream) pending() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.replaying
+}
+
+// received to check if replayed event was received from stream events already
+func (s *stream) received(data string) bool {
+	e := events.Event{}
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return e.EventCreatedOn < s.cutoff || s.seen[data]
+}
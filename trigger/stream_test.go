@@ -0,0 +1,54 @@
+package trigger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/queue"
+)
+
+func initStream() (*stream, queue.Queue) {
+	c := queue.DefaultConfig()
+	c.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "trigger",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	q := queue.New(context.Background(), c)
+	_ = q.Init(context.Background())
+
+	return newStream(q), q
+}
+
+func TestStream(t *testing.T) {
+	s, q := initStream()
+	ctx := context.Background()
+
+	defer func(q queue.Queue, ctx context.Context) {
+		_ = q.Close(ctx)
+	}(q, ctx)
+
+	_ = s.Put(ctx, `{"type":"ref-updated","eventCreatedOn":100}`)
+	_ = s.Put(ctx, `{"type":"patchset-created","eventCreatedOn":101}`)
+
+	r, _ := q.Get(ctx)
+	assert.Equal(t, `{"type":"ref-updated","eventCreatedOn":100}`, <-r)
+
+	s.begin()
+	assert.Equal(t, true, s.pending())
+
+	// Events received after terminated are kept while replaying
+	_ = s.Put(ctx, `{"type":"comment-added","eventCreatedOn":120}`)
+
+	assert.Equal(t, true, s.received(`{"type":"ref-updated","eventCreatedOn":100}`))
+	assert.Equal(t, true, s.received(`{"type":"patchset-created","eventCreatedOn":101}`))
+	assert.Equal(t, false, s.received(`{"type":"change-merged","eventCreatedOn":101}`))
+	assert.Equal(t, false, s.received(`{"type":"change-merged","eventCreatedOn":110}`))
+	assert.Equal(t, true, s.received(`{"type":"comment-added","eventCreatedOn":120}`))
+
+	s.end()
+	assert.Equal(t, false, s.pending())
+}
@@ -2,8 +2,15 @@ package trigger
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"maps"
+	mathRand "math/rand/v2"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/pkg/errors"
@@ -11,6 +18,8 @@ import (
 
 	"github.com/gerrittrigger/trigger/config"
 	"github.com/gerrittrigger/trigger/connect"
+	"github.com/gerrittrigger/trigger/deadletter"
+	"github.com/gerrittrigger/trigger/dispatch"
 	"github.com/gerrittrigger/trigger/events"
 	"github.com/gerrittrigger/trigger/filter"
 	"github.com/gerrittrigger/trigger/playback"
@@ -18,33 +27,63 @@ import (
 	"github.com/gerrittrigger/trigger/queue"
 	"github.com/gerrittrigger/trigger/report"
 	"github.com/gerrittrigger/trigger/watchdog"
+	"github.com/gerrittrigger/trigger/worker"
 )
 
 const (
-	num = -1
+	groupLength      = 8
+	num              = -1
+	reconnectBackoff = time.Second
+	reconnectMax     = time.Minute
+	retryBackoff     = time.Second
+	shardProject     = "project"
+	streamCommand    = "stream-events"
 )
 
 type Trigger interface {
 	Init(context.Context) error
 	Deinit(context.Context) error
-	Run(context.Context, []config.Event, []config.Project, chan map[string]string) error
+	Run(context.Context, []config.Job, chan *dispatch.Request) error
+	DryRun(context.Context, string) ([]Match, error)
+	Explain(context.Context, string) ([]Explanation, error)
 }
 
 type Config struct {
-	Config   config.Config
-	Filter   filter.Filter
-	Logger   hclog.Logger
-	Playback playback.Playback
-	Query    query.Query
-	Queue    queue.Queue
-	Report   report.Report
-	Ssh      connect.Ssh
-	Watchdog watchdog.Watchdog
+	Config     config.Config
+	DeadLetter deadletter.DeadLetter
+	Filter     filter.Filter
+	Logger     hclog.Logger
+	Playback   playback.Playback
+	Query      query.Query
+	Queue      queue.Queue
+	Quiet      queue.Quiet
+	Report     report.Report
+	Ssh        connect.Ssh
+	Watchdog   watchdog.Watchdog
+	Worker     worker.Worker
+}
+
+// Match to store matched job and the indexes of matched rules in dry run
+type Match struct {
+	Connect  string            `json:"connect"`
+	Events   []int             `json:"events"`
+	Job      string            `json:"job"`
+	Params   map[string]string `json:"params"`
+	Projects []int             `json:"projects"`
+}
+
+// Explanation to store the filter trace of one job
+type Explanation struct {
+	Connect string        `json:"connect"`
+	Job     string        `json:"job"`
+	Trace   *filter.Trace `json:"trace"`
 }
 
 type trigger struct {
-	cfg *Config
-	pb  bool
+	cfg    *Config
+	cursor *cursor
+	pb     bool
+	stream *stream
 }
 
 func New(_ context.Context, cfg *Config) Trigger {
@@ -60,6 +99,10 @@ func DefaultConfig() *Config {
 func (t *trigger) Init(ctx context.Context) error {
 	t.cfg.Logger.Debug("trigger: Init")
 
+	if err := t.cfg.DeadLetter.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init deadletter")
+	}
+
 	if err := t.cfg.Filter.Init(ctx); err != nil {
 		return errors.Wrap(err, "failed to init filter")
 	}
@@ -74,6 +117,8 @@ func (t *trigger) Init(ctx context.Context) error {
 		t.pb = false
 	}
 
+	t.cursor = newCursor(t.cfg.Playback)
+
 	if err := t.cfg.Query.Init(ctx); err != nil {
 		return errors.Wrap(err, "failed to init query")
 	}
@@ -82,6 +127,12 @@ func (t *trigger) Init(ctx context.Context) error {
 		return errors.Wrap(err, "failed to init queue")
 	}
 
+	t.stream = newStream(t.cfg.Queue)
+
+	if err := t.cfg.Quiet.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init quiet")
+	}
+
 	if err := t.cfg.Report.Init(ctx); err != nil {
 		return errors.Wrap(err, "failed to init report")
 	}
@@ -90,48 +141,156 @@ func (t *trigger) Init(ctx context.Context) error {
 		return errors.Wrap(err, "failed to init ssh")
 	}
 
+	if err := t.cfg.Watchdog.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init watchdog")
+	}
+
+	if err := t.cfg.Worker.Init(ctx); err != nil {
+		return errors.Wrap(err, "failed to init worker")
+	}
+
 	return nil
 }
 
 func (t *trigger) Deinit(ctx context.Context) error {
 	t.cfg.Logger.Debug("trigger: Deinit")
 
+	_ = t.cfg.Worker.Deinit(ctx)
+	_ = t.cfg.Watchdog.Stop(ctx)
+	_ = t.cfg.Watchdog.Deinit(ctx)
 	_ = t.cfg.Ssh.Deinit(ctx)
 	_ = t.cfg.Report.Deinit(ctx)
+	_ = t.cfg.Queue.Close(ctx)
 	_ = t.cfg.Queue.Deinit(ctx)
+	_ = t.cfg.Quiet.Deinit(ctx)
 	_ = t.cfg.Query.Deinit(ctx)
 	_ = t.cfg.Playback.Deinit(ctx)
 	_ = t.cfg.Filter.Deinit(ctx)
+	_ = t.cfg.DeadLetter.Deinit(ctx)
 
 	return nil
 }
 
-func (t *trigger) Run(ctx context.Context, _events []config.Event, projects []config.Project, param chan map[string]string) error {
+func (t *trigger) Run(ctx context.Context, jobs []config.Job, param chan *dispatch.Request) error {
 	t.cfg.Logger.Debug("trigger: Run")
 
 	if t.pb {
 		if err := t.playbackEvent(ctx); err != nil {
+			close(param)
 			return errors.Wrap(err, "failed to playback event")
 		}
 	}
 
 	t.fetchEvent(ctx)
 
-	if _events == nil || len(_events) == 0 {
-		_events = t.cfg.Config.Spec.Trigger.Events
+	if err := t.cfg.Watchdog.Start(ctx); err != nil {
+		close(param)
+		return errors.Wrap(err, "failed to start watchdog")
 	}
 
-	if projects == nil || len(projects) == 0 {
-		projects = t.cfg.Config.Spec.Trigger.Projects
+	if len(jobs) == 0 {
+		jobs = t.defaultJobs()
 	}
 
-	if err := t.postReport(ctx, _events, projects, param); err != nil {
+	if err := t.postReport(ctx, jobs, param); err != nil {
 		return errors.Wrap(err, "failed to post report")
 	}
 
 	return nil
 }
 
+func (t *trigger) DryRun(ctx context.Context, data string) ([]Match, error) {
+	t.cfg.Logger.Debug("trigger: DryRun")
+
+	e := events.Event{}
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal json")
+	}
+
+	jobs := t.defaultJobs()
+
+	reqs, err := t.matchJobs(ctx, jobs, &e)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to match jobs")
+	}
+
+	buf := make([]Match, 0, len(reqs))
+
+	for _, req := range reqs {
+		m := Match{
+			Connect:  t.cfg.Config.Spec.Connect.Name,
+			Events:   []int{},
+			Job:      req.Job,
+			Params:   req.Params,
+			Projects: []int{},
+		}
+		job := jobs[slices.IndexFunc(jobs, func(j config.Job) bool { return j.Name == req.Job })]
+		for i := range job.Events {
+			for j := range job.Projects {
+				if ok, _ := t.cfg.Filter.Run(ctx, job.Events[i:i+1], job.Projects[j:j+1], &e); !ok {
+					continue
+				}
+				if !slices.Contains(m.Events, i) {
+					m.Events = append(m.Events, i)
+				}
+				if !slices.Contains(m.Projects, j) {
+					m.Projects = append(m.Projects, j)
+				}
+			}
+		}
+		buf = append(buf, m)
+	}
+
+	return buf, nil
+}
+
+func (t *trigger) Explain(ctx context.Context, data string) ([]Explanation, error) {
+	t.cfg.Logger.Debug("trigger: Explain")
+
+	e := events.Event{}
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal json")
+	}
+
+	jobs := t.defaultJobs()
+	buf := make([]Explanation, 0, len(jobs))
+
+	for i := range jobs {
+		r, err := t.cfg.Filter.Explain(ctx, jobs[i].Events, jobs[i].Projects, &e)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to explain filter")
+		}
+		buf = append(buf, Explanation{
+			Connect: t.cfg.Config.Spec.Connect.Name,
+			Job:     jobs[i].Name,
+			Trace:   r,
+		})
+	}
+
+	return buf, nil
+}
+
+func (t *trigger) defaultJobs() []config.Job {
+	if len(t.cfg.Config.Spec.Trigger.Jobs) != 0 {
+		var jobs []config.Job
+		for _, item := range t.cfg.Config.Spec.Trigger.Jobs {
+			if len(item.Connects) == 0 || slices.Contains(item.Connects, t.cfg.Config.Spec.Connect.Name) {
+				jobs = append(jobs, item)
+			}
+		}
+		return jobs
+	}
+
+	// Top-level events and projects are treated as one job
+	return []config.Job{
+		{
+			Events:   t.cfg.Config.Spec.Trigger.Events,
+			Name:     t.cfg.Config.MetaData.Name,
+			Projects: t.cfg.Config.Spec.Trigger.Projects,
+		},
+	}
+}
+
 func (t *trigger) playbackEvent(ctx context.Context) error {
 	t.cfg.Logger.Debug("trigger: playbackEvent")
 
@@ -157,57 +316,207 @@ func (t *trigger) playbackEvent(ctx context.Context) error {
 func (t *trigger) fetchEvent(ctx context.Context) {
 	t.cfg.Logger.Debug("trigger: fetchEvent")
 
-	_ = t.cfg.Ssh.Start(ctx, "stream-events", t.cfg.Queue)
+	go t.superviseStream(ctx)
 }
 
-func (t *trigger) postReport(ctx context.Context, _events []config.Event, projects []config.Project, param chan map[string]string) error {
-	t.cfg.Logger.Debug("trigger: postReport")
+// superviseStream to restart stream events after termination with backoff and jitter,
+// and replay events missed meanwhile by playback
+func (t *trigger) superviseStream(ctx context.Context) {
+	backoff := reconnectBackoff
+
+	for {
+		started := time.Now()
+
+		err := t.startStream(ctx)
+		if err == nil {
+			t.cfg.Logger.Info("trigger: superviseStream", "status", "streaming")
+			if t.stream.pending() {
+				t.replayEvent(ctx)
+			}
+			err = t.cfg.Ssh.Wait(ctx)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		t.cfg.Logger.Warn("trigger: superviseStream", "error", err.Error())
+		t.stream.begin()
 
-	helper := func(data string) error {
-		e := events.Event{}
-		if err := json.Unmarshal([]byte(data), &e); err != nil {
-			return errors.Wrap(err, "failed to unmarshal json")
+		// Backoff is reset if stream lasted long enough
+		if time.Since(started) >= reconnectMax {
+			backoff = reconnectBackoff
 		}
-		if err := t.cfg.Query.Run(ctx, _events, projects, &e, t.cfg.Ssh); err != nil {
-			return errors.Wrap(err, "failed to run query")
+
+		select {
+		case <-time.After(jitter(backoff)):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff = min(backoff*2, reconnectMax)
+	}
+}
+
+// startStream to start stream events, and reconnect only if failed since watchdog may reconnect already
+func (t *trigger) startStream(ctx context.Context) error {
+	if err := t.cfg.Ssh.Start(ctx, streamCommand, t.stream); err == nil {
+		return nil
+	}
+
+	if err := t.cfg.Ssh.Reconnect(ctx); err != nil {
+		return errors.Wrap(err, "failed to reconnect")
+	}
+
+	return t.cfg.Ssh.Start(ctx, streamCommand, t.stream)
+}
+
+// replayEvent to put events missed during outage, except those received from stream events
+func (t *trigger) replayEvent(ctx context.Context) {
+	t.cfg.Logger.Debug("trigger: replayEvent")
+
+	defer t.stream.end()
+
+	if !t.pb {
+		t.cfg.Logger.Warn("trigger: replayEvent", "error", "events during outage not replayed without spec.playback.eventsApi")
+		return
+	}
+
+	var b []string
+
+	if _, err := t.retry(ctx, func() error {
+		var err error
+		b, err = t.cfg.Playback.Load(ctx)
+		return err
+	}); err != nil {
+		t.cfg.Logger.Error("trigger: replayEvent", "error", err.Error())
+		return
+	}
+
+	count := 0
+
+	for i := range b {
+		if t.stream.received(b[i]) {
+			continue
+		}
+		if err := t.cfg.Queue.Put(ctx, b[i]); err != nil {
+			t.cfg.Logger.Error("trigger: replayEvent", "error", err.Error())
+			return
+		}
+		count++
+	}
+
+	t.cfg.Logger.Info("trigger: replayEvent", "replayed", count, "loaded", len(b))
+}
+
+// jitter to randomize backoff in [backoff/2, backoff) to spread reconnections
+func jitter(backoff time.Duration) time.Duration {
+	return backoff/2 + mathRand.N(backoff/2)
+}
+
+func (t *trigger) postReport(ctx context.Context, jobs []config.Job, param chan *dispatch.Request) error {
+	t.cfg.Logger.Debug("trigger: postReport")
+
+	var _events []config.Event
+	var projects []config.Project
+
+	for i := range jobs {
+		_events = append(_events, jobs[i].Events...)
+		projects = append(projects, jobs[i].Projects...)
+	}
+
+	helper := func(e *events.Event) ([]*dispatch.Request, error) {
+		if err := t.cfg.Query.Run(ctx, _events, projects, e, t.cfg.Ssh); err != nil {
+			return nil, errors.Wrap(err, "failed to run query")
 		}
-		m, err := t.cfg.Filter.Run(ctx, _events, projects, &e)
+		reqs, err := t.matchJobs(ctx, jobs, e)
 		if err != nil {
-			return errors.Wrap(err, "failed to run filter")
+			return nil, errors.Wrap(err, "failed to match jobs")
 		}
-		if m {
-			b, err := t.cfg.Report.Run(ctx, &e)
-			if err != nil {
-				return errors.Wrap(err, "failed to run report")
+		return reqs, nil
+	}
+
+	// Failed event is moved to dead letter instead of stopping processing
+	process := func(data string, e *events.Event) error {
+		if req := t.cancelJobs(jobs, e); req != nil {
+			if err := t.cfg.Quiet.Put(ctx, req); err != nil {
+				return errors.Wrap(err, "failed to put quiet")
+			}
+		}
+		var reqs []*dispatch.Request
+		attempts, err := t.retry(ctx, func() error {
+			var err error
+			reqs, err = helper(e)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
-			param <- b
+			t.deadLetter(ctx, data, attempts, err)
 		}
-		if t.pb {
-			if err := t.cfg.Playback.Store(ctx, data); err != nil {
-				return errors.Wrap(err, "failed to run store")
+		for i := range reqs {
+			if err := t.cfg.Quiet.Put(ctx, reqs[i]); err != nil {
+				return errors.Wrap(err, "failed to put quiet")
 			}
 		}
+		t.done(ctx, data)
 		return nil
 	}
 
-	defer func() {
-		_ = t.cfg.Queue.Close(ctx)
-		close(param)
-	}()
-
 	r, err := t.cfg.Queue.Get(ctx)
 	if err != nil {
+		close(param)
 		return errors.Wrap(err, "failed to get queue")
 	}
 
+	out, err := t.cfg.Quiet.Get(ctx)
+	if err != nil {
+		close(param)
+		return errors.Wrap(err, "failed to get quiet")
+	}
+
 	g, _ := errgroup.WithContext(ctx)
 	g.SetLimit(num)
 
+	done := make(chan struct{})
+
 	g.Go(func() error {
+		defer close(done)
+		for item := range out {
+			param <- item
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		defer close(param)
+		defer func() {
+			<-done
+		}()
+		// Wait for events in workers and requests in quiet period before closing param
+		defer func() {
+			_ = t.cfg.Worker.Wait(ctx)
+			_ = t.cfg.Quiet.Close(ctx)
+		}()
 		for {
 			select {
-			case buf := <-r:
-				if err := helper(buf); err != nil {
+			case buf, ok := <-r:
+				if !ok {
+					return nil
+				}
+				e := events.Event{}
+				if err := json.Unmarshal([]byte(buf), &e); err != nil {
+					t.deadLetter(ctx, buf, 1, errors.Wrap(err, "failed to unmarshal json"))
+					t.done(ctx, buf)
+					continue
+				}
+				if t.pb {
+					t.cursor.track(buf)
+				}
+				if err := t.cfg.Worker.Run(ctx, t.shardKey(&e), func() error {
+					return process(buf, &e)
+				}); err != nil {
 					return err
 				}
 			case <-ctx.Done():
@@ -218,3 +527,154 @@ func (t *trigger) postReport(ctx context.Context, _events []config.Event, projec
 
 	return nil
 }
+
+// retry to run helper with backoff doubled on each retry, and return the number of attempts
+func (t *trigger) retry(ctx context.Context, helper func() error) (int, error) {
+	var err error
+
+	backoff := time.Duration(t.cfg.Config.Spec.Retry.BackoffSeconds) * time.Second
+	if backoff <= 0 {
+		backoff = retryBackoff
+	}
+
+	for i := 0; i <= t.cfg.Config.Spec.Retry.Count; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return i, ctx.Err()
+			}
+		}
+		if err = helper(); err == nil {
+			return i + 1, nil
+		}
+		t.cfg.Logger.Warn("trigger: retry", "attempt", i+1, "error", err.Error())
+	}
+
+	return t.cfg.Config.Spec.Retry.Count + 1, err
+}
+
+func (t *trigger) deadLetter(ctx context.Context, data string, attempts int, err error) {
+	l := deadletter.Letter{
+		Attempts: attempts,
+		Connect:  t.cfg.Config.Spec.Connect.Name,
+		Error:    err.Error(),
+		Event:    data,
+		Time:     time.Now(),
+	}
+
+	if err := t.cfg.DeadLetter.Put(ctx, &l); err != nil {
+		t.cfg.Logger.Error("trigger: deadLetter", "error", err.Error())
+	}
+}
+
+// done to store and acknowledge event processed or moved to dead letter
+func (t *trigger) done(ctx context.Context, data string) {
+	if t.pb {
+		if err := t.cursor.done(ctx, data); err != nil {
+			t.cfg.Logger.Error("trigger: done", "error", err.Error())
+		}
+	}
+
+	if err := t.cfg.Queue.Ack(ctx, data); err != nil {
+		t.cfg.Logger.Error("trigger: done", "error", err.Error())
+	}
+}
+
+// shardKey to process events of the same change or project in order
+func (t *trigger) shardKey(event *events.Event) string {
+	project := event.Change.Project
+	if project == "" {
+		project = event.RefUpdate.Project
+	}
+
+	if project == "" {
+		project = event.ProjectName
+	}
+
+	if strings.ToLower(t.cfg.Config.Spec.Worker.Shard) == shardProject || event.Change.Number <= 0 {
+		return project
+	}
+
+	return project + "/" + strconv.Itoa(event.Change.Number)
+}
+
+func (t *trigger) cancelJobs(jobs []config.Job, event *events.Event) *dispatch.Request {
+	switch event.Type {
+	case events.EventsChangeAbandoned, events.EventsChangeDeleted, events.EventsChangeRestored, events.EventsPatchsetCreated:
+	default:
+		return nil
+	}
+
+	if event.Change.Number <= 0 || !slices.ContainsFunc(jobs, func(j config.Job) bool { return j.Cancel }) {
+		return nil
+	}
+
+	return &dispatch.Request{
+		Cancel:  true,
+		Connect: t.cfg.Config.Spec.Connect.Name,
+		Event:   event,
+	}
+}
+
+func (t *trigger) matchJobs(ctx context.Context, jobs []config.Job, event *events.Event) ([]*dispatch.Request, error) {
+	var b map[string]string
+	var reqs []*dispatch.Request
+
+	for i := range jobs {
+		m, err := t.cfg.Filter.Run(ctx, jobs[i].Events, jobs[i].Projects, event)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to run filter")
+		}
+		if !m {
+			continue
+		}
+		if b == nil {
+			b, err = t.cfg.Report.Run(ctx, event)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to run report")
+			}
+		}
+		reqs = append(reqs, &dispatch.Request{
+			Connect:  t.cfg.Config.Spec.Connect.Name,
+			Dispatch: jobs[i].Dispatch,
+			Event:    event,
+			Job:      jobs[i].Name,
+			Params:   maps.Clone(b),
+		})
+	}
+
+	if len(reqs) == 0 {
+		return reqs, nil
+	}
+
+	// Group requests of one event to summarize their builds
+	group, err := groupId()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create group")
+	}
+
+	names := make([]string, len(reqs))
+
+	for i := range reqs {
+		names[i] = reqs[i].Job
+	}
+
+	for i := range reqs {
+		reqs[i].Group = group
+		reqs[i].Jobs = names
+	}
+
+	return reqs, nil
+}
+
+func groupId() (string, error) {
+	buf := make([]byte, groupLength)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
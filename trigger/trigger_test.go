@@ -1,11 +1,347 @@
 package trigger
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/connect"
+	"github.com/gerrittrigger/trigger/dispatch"
+	"github.com/gerrittrigger/trigger/events"
+	"github.com/gerrittrigger/trigger/filter"
+	"github.com/gerrittrigger/trigger/playback"
+	"github.com/gerrittrigger/trigger/queue"
+	"github.com/gerrittrigger/trigger/report"
 )
 
+func initTrigger() trigger {
+	t := trigger{
+		cfg: DefaultConfig(),
+	}
+
+	t.cfg.Config = config.Config{}
+
+	t.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "trigger",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	f := filter.DefaultConfig()
+	f.Logger = t.cfg.Logger
+	t.cfg.Filter = filter.New(context.Background(), f)
+
+	r := report.DefaultConfig()
+	r.Logger = t.cfg.Logger
+	t.cfg.Report = report.New(context.Background(), r)
+
+	return t
+}
+
 func TestTrigger(t *testing.T) {
 	assert.Equal(t, nil, nil)
 }
+
+func TestDefaultJobs(t *testing.T) {
+	_t := initTrigger()
+
+	_t.cfg.Config.MetaData.Name = "trigger"
+	_t.cfg.Config.Spec.Trigger.Events = []config.Event{{Name: events.EventsPatchsetCreated}}
+
+	jobs := _t.defaultJobs()
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, "trigger", jobs[0].Name)
+	assert.Equal(t, 1, len(jobs[0].Events))
+
+	_t.cfg.Config.Spec.Trigger.Jobs = []config.Job{{Name: "job1"}, {Name: "job2"}}
+
+	jobs = _t.defaultJobs()
+	assert.Equal(t, 2, len(jobs))
+
+	_t.cfg.Config.Spec.Connect.Name = "gerrit1"
+	_t.cfg.Config.Spec.Trigger.Jobs = []config.Job{
+		{Connects: []string{"gerrit1", "gerrit2"}, Name: "job1"},
+		{Connects: []string{"gerrit2"}, Name: "job2"},
+		{Name: "job3"},
+	}
+
+	jobs = _t.defaultJobs()
+	assert.Equal(t, 2, len(jobs))
+	assert.Equal(t, "job1", jobs[0].Name)
+	assert.Equal(t, "job3", jobs[1].Name)
+}
+
+func TestMatchJobs(t *testing.T) {
+	_t := initTrigger()
+	ctx := context.Background()
+
+	project := config.Project{
+		Branches: []config.Match{{Pattern: "main", Type: "plain"}},
+		Repo:     config.Match{Pattern: "test", Type: "plain"},
+	}
+
+	jobs := []config.Job{
+		{
+			Dispatch: "exec",
+			Events:   []config.Event{{Name: events.EventsPatchsetCreated}},
+			Name:     "build",
+			Projects: []config.Project{project},
+		},
+		{
+			Events:   []config.Event{{Name: events.EventsChangeMerged}},
+			Name:     "deploy",
+			Projects: []config.Project{project},
+		},
+		{
+			Events:   []config.Event{{Name: events.EventsPatchsetCreated}},
+			Name:     "lint",
+			Projects: []config.Project{project},
+		},
+	}
+
+	event := events.Event{
+		Change:  events.Change{Branch: "main"},
+		Project: "test",
+		Type:    events.EventsPatchsetCreated,
+	}
+
+	reqs, err := _t.matchJobs(ctx, jobs, &event)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(reqs))
+	assert.Equal(t, "build", reqs[0].Job)
+	assert.Equal(t, "exec", reqs[0].Dispatch)
+	assert.Equal(t, "lint", reqs[1].Job)
+	assert.Equal(t, "", reqs[1].Dispatch)
+	assert.Equal(t, events.EventsPatchsetCreated, reqs[1].Params["GERRIT_EVENT_TYPE"])
+	assert.NotEqual(t, "", reqs[0].Group)
+	assert.Equal(t, reqs[0].Group, reqs[1].Group)
+
+	event.Type = events.EventsCommentAdded
+
+	reqs, err = _t.matchJobs(ctx, jobs, &event)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(reqs))
+}
+
+func TestDryRun(t *testing.T) {
+	_t := initTrigger()
+	ctx := context.Background()
+
+	_t.cfg.Config.Spec.Connect.Name = "gerrit"
+	_t.cfg.Config.Spec.Trigger.Jobs = []config.Job{
+		{
+			Events: []config.Event{{Name: events.EventsChangeMerged}, {Name: events.EventsPatchsetCreated}},
+			Name:   "build",
+			Projects: []config.Project{
+				{
+					Branches: []config.Match{{Pattern: "dev", Type: "plain"}},
+					Repo:     config.Match{Pattern: "test", Type: "plain"},
+				},
+				{
+					Branches: []config.Match{{Pattern: "main", Type: "plain"}},
+					Repo:     config.Match{Pattern: "test", Type: "plain"},
+				},
+			},
+		},
+	}
+
+	_, err := _t.DryRun(ctx, "invalid")
+	assert.NotEqual(t, nil, err)
+
+	buf, err := _t.DryRun(ctx, `{"type":"patchset-created","change":{"branch":"main"},"project":"test"}`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(buf))
+	assert.Equal(t, "gerrit", buf[0].Connect)
+	assert.Equal(t, "build", buf[0].Job)
+	assert.Equal(t, []int{1}, buf[0].Events)
+	assert.Equal(t, []int{1}, buf[0].Projects)
+
+	buf, err = _t.DryRun(ctx, `{"type":"patchset-created","change":{"branch":"invalid"},"project":"test"}`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(buf))
+}
+
+func TestCancelJobs(t *testing.T) {
+	_t := initTrigger()
+
+	_t.cfg.Config.Spec.Connect.Name = "gerrit"
+
+	jobs := []config.Job{{Name: "build"}}
+
+	event := events.Event{
+		Change: events.Change{Number: 1},
+		Type:   events.EventsPatchsetCreated,
+	}
+
+	assert.Equal(t, (*dispatch.Request)(nil), _t.cancelJobs(jobs, &event))
+
+	jobs[0].Cancel = true
+
+	req := _t.cancelJobs(jobs, &event)
+	assert.Equal(t, true, req.Cancel)
+	assert.Equal(t, "gerrit", req.Connect)
+	assert.Equal(t, &event, req.Event)
+
+	event.Type = events.EventsCommentAdded
+	assert.Equal(t, (*dispatch.Request)(nil), _t.cancelJobs(jobs, &event))
+}
+
+func TestShardKey(t *testing.T) {
+	_t := initTrigger()
+
+	event := events.Event{
+		Change: events.Change{Number: 1, Project: "project"},
+	}
+
+	assert.Equal(t, "project/1", _t.shardKey(&event))
+
+	_t.cfg.Config.Spec.Worker.Shard = "project"
+	assert.Equal(t, "project", _t.shardKey(&event))
+
+	event = events.Event{
+		RefUpdate: events.RefUpdate{Project: "ref"},
+	}
+
+	_t.cfg.Config.Spec.Worker.Shard = ""
+	assert.Equal(t, "ref", _t.shardKey(&event))
+}
+
+func TestRetry(t *testing.T) {
+	_t := initTrigger()
+	ctx := context.Background()
+
+	count := 0
+
+	attempts, err := _t.retry(ctx, func() error {
+		count++
+		return errors.New("invalid")
+	})
+
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 1, attempts)
+
+	_t.cfg.Config.Spec.Retry.Count = 2
+	count = 0
+
+	attempts, err = _t.retry(ctx, func() error {
+		count++
+		if count < 2 {
+			return errors.New("invalid")
+		}
+		return nil
+	})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, attempts)
+}
+
+// testSsh to fake connection lost once with stream events
+type testSsh struct {
+	connect.Ssh
+	lost      bool
+	mutex     sync.Mutex
+	reconnect int
+	start     int
+}
+
+func (s *testSsh) Start(ctx context.Context, _ string, q queue.Queue) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.start++
+
+	if s.lost {
+		return errors.New("invalid client")
+	}
+
+	return q.Put(ctx, `{"type":"ref-updated","eventCreatedOn":100}`)
+}
+
+func (s *testSsh) Wait(ctx context.Context) error {
+	s.mutex.Lock()
+	start := s.start
+	s.lost = start == 1
+	s.mutex.Unlock()
+
+	if start == 1 {
+		return errors.New("stream ended")
+	}
+
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func (s *testSsh) Reconnect(_ context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lost = false
+	s.reconnect++
+
+	return nil
+}
+
+// testPlayback to fake events missed during outage
+type testPlayback struct {
+	playback.Playback
+}
+
+func (p *testPlayback) Load(_ context.Context) ([]string, error) {
+	return []string{
+		`{"type":"ref-updated","eventCreatedOn":100}`,
+		`{"type":"patchset-created","eventCreatedOn":110}`,
+	}, nil
+}
+
+func TestSuperviseStream(t *testing.T) {
+	_t := initTrigger()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	defer cancel()
+
+	s, q := initStream()
+
+	defer func(q queue.Queue) {
+		_ = q.Close(context.Background())
+	}(q)
+
+	_ssh := &testSsh{}
+
+	_t.cfg.Playback = &testPlayback{}
+	_t.cfg.Queue = q
+	_t.cfg.Ssh = _ssh
+	_t.pb = true
+	_t.stream = s
+
+	_t.fetchEvent(ctx)
+
+	r, _ := q.Get(ctx)
+
+	// Event received from stream again is not replayed
+	assert.Equal(t, `{"type":"ref-updated","eventCreatedOn":100}`, <-r)
+	assert.Equal(t, `{"type":"ref-updated","eventCreatedOn":100}`, <-r)
+	assert.Equal(t, `{"type":"patchset-created","eventCreatedOn":110}`, <-r)
+
+	select {
+	case buf := <-r:
+		t.Errorf("unexpected event %s", buf)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_ssh.mutex.Lock()
+	assert.Equal(t, 3, _ssh.start)
+	assert.Equal(t, 1, _ssh.reconnect)
+	_ssh.mutex.Unlock()
+
+	assert.Equal(t, false, s.pending())
+
+	jitter := jitter(reconnectBackoff)
+	assert.GreaterOrEqual(t, jitter, reconnectBackoff/2)
+	assert.Less(t, jitter, reconnectBackoff)
+}
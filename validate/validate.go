@@ -0,0 +1,556 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/gerrittrigger/go-antpath/antpath"
+	"github.com/gerrittrigger/trigger/config"
+	"github.com/gerrittrigger/trigger/events"
+)
+
+const (
+	authAgent         = "agent"
+	authKeyfile       = "keyfile"
+	dispatchExec      = "exec"
+	dispatchJenkins   = "jenkins"
+	dispatchLog       = "log"
+	dispatchWebhook   = "webhook"
+	eventSep          = "-"
+	fingerprintPrefix = "SHA256:"
+	matchPath         = "path"
+	matchPlain        = "plain"
+	matchRegExp       = "regexp"
+	queueDisk         = "disk"
+	queueMemory       = "memory"
+	shardChange       = "change"
+	shardProject      = "project"
+)
+
+var (
+	eventNames = []string{
+		events.EventsBatchRefUpdated,
+		events.EventsChangeAbandoned,
+		events.EventsChangeDeleted,
+		events.EventsChangeMerged,
+		events.EventsChangeRestored,
+		events.EventsCommentAdded,
+		events.EventsCommitReceived,
+		events.EventsHashtagsChanged,
+		events.EventsPatchsetCreated,
+		events.EventsPrivateStateChanged,
+		events.EventsProjectCreated,
+		events.EventsProjectHeadUpdated,
+		events.EventsRefReceived,
+		events.EventsRefReplicated,
+		events.EventsRefUpdated,
+		events.EventsReviewerAdded,
+		events.EventsReviewerDeleted,
+		events.EventsTopicChanged,
+		events.EventsVoteDeleted,
+		events.EventsWipStateChanged,
+	}
+)
+
+type Validate interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Run(context.Context, []byte) (*config.Config, error)
+}
+
+type Config struct {
+	Logger hclog.Logger
+}
+
+type validate struct {
+	cfg *Config
+}
+
+// issue to store one validation error located by YAML path, e.g., "spec.trigger.jobs[0].name"
+type issue struct {
+	path    string
+	message string
+}
+
+func New(_ context.Context, cfg *Config) Validate {
+	return &validate{
+		cfg: cfg,
+	}
+}
+
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+func (v *validate) Init(_ context.Context) error {
+	v.cfg.Logger.Debug("validate: Init")
+
+	return nil
+}
+
+func (v *validate) Deinit(_ context.Context) error {
+	v.cfg.Logger.Debug("validate: Deinit")
+
+	return nil
+}
+
+func (v *validate) Run(ctx context.Context, data []byte) (*config.Config, error) {
+	v.cfg.Logger.Debug("validate: Run")
+
+	c := config.New()
+
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(true)
+
+	if err := d.Decode(c); err != nil {
+		return c, errors.Wrap(err, "failed to decode")
+	}
+
+	var node yaml.Node
+
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return c, errors.Wrap(err, "failed to unmarshal")
+	}
+
+	buf := v.validateConnects(ctx, c)
+	buf = append(buf, v.validateDispatch(ctx, c)...)
+	buf = append(buf, v.validateQueue(ctx, c)...)
+	buf = append(buf, v.validateRetry(ctx, c)...)
+	buf = append(buf, v.validateReview(ctx, c)...)
+	buf = append(buf, v.validateTrigger(ctx, c)...)
+	buf = append(buf, v.validateWorker(ctx, c)...)
+
+	if len(buf) == 0 {
+		return c, nil
+	}
+
+	msg := make([]string, len(buf))
+
+	for i := range buf {
+		msg[i] = fmt.Sprintf("line %d: %s: %s", v.line(&node, buf[i].path), buf[i].path, buf[i].message)
+	}
+
+	return c, errors.New("invalid config:\n  " + strings.Join(msg, "\n  "))
+}
+
+func (v *validate) validateConnects(_ context.Context, cfg *config.Config) []issue {
+	helper := func(path string, c *config.Connect) []issue {
+		var buf []issue
+		if c.Hostname == "" {
+			buf = append(buf, issue{path + ".hostname", "required"})
+		}
+		for i, item := range c.Ssh.AuthMethods {
+			if item != authAgent && item != authKeyfile {
+				buf = append(buf, issue{path + ".ssh.authMethods[" + strconv.Itoa(i) + "]", fmt.Sprintf("invalid method %q", item)})
+			}
+		}
+		// Keyfile is optional with ssh-agent by default
+		if c.Ssh.Keyfile == "" && (slices.Contains(c.Ssh.AuthMethods, authKeyfile) || c.Ssh.Certfile != "") {
+			buf = append(buf, issue{path + ".ssh.keyfile", "required"})
+		}
+		if c.Ssh.Port <= 0 {
+			buf = append(buf, issue{path + ".ssh.port", "required"})
+		}
+		if c.Ssh.Username == "" {
+			buf = append(buf, issue{path + ".ssh.username", "required"})
+		}
+		if c.Ssh.Sessions < 0 {
+			buf = append(buf, issue{path + ".ssh.sessions", "invalid value"})
+		}
+		if c.Ssh.TimeoutSeconds < 0 {
+			buf = append(buf, issue{path + ".ssh.timeoutSeconds", "invalid value"})
+		}
+		for i, item := range c.Ssh.Fingerprints {
+			if !strings.HasPrefix(item, fingerprintPrefix) {
+				buf = append(buf, issue{path + ".ssh.fingerprints[" + strconv.Itoa(i) + "]", fmt.Sprintf("invalid fingerprint %q", item)})
+			}
+		}
+		return buf
+	}
+
+	if len(cfg.Spec.Connects) == 0 {
+		return helper("spec.connect", &cfg.Spec.Connect)
+	}
+
+	var buf []issue
+
+	names := map[string]bool{}
+
+	for i := range cfg.Spec.Connects {
+		path := "spec.connects[" + strconv.Itoa(i) + "]"
+		buf = append(buf, helper(path, &cfg.Spec.Connects[i])...)
+		name := cfg.Spec.Connects[i].Name
+		if name == "" {
+			buf = append(buf, issue{path + ".name", "required"})
+		} else if names[name] {
+			buf = append(buf, issue{path + ".name", "duplicate name " + strconv.Quote(name)})
+		}
+		names[name] = true
+	}
+
+	return buf
+}
+
+func (v *validate) validateDispatch(_ context.Context, cfg *config.Config) []issue {
+	var buf []issue
+
+	names := map[string]bool{}
+
+	for i := range cfg.Spec.Dispatch {
+		path := "spec.dispatch[" + strconv.Itoa(i) + "]"
+		name := cfg.Spec.Dispatch[i].Name
+		if name == "" {
+			buf = append(buf, issue{path + ".name", "required"})
+		} else if names[name] {
+			buf = append(buf, issue{path + ".name", "duplicate name " + strconv.Quote(name)})
+		}
+		names[name] = true
+		buf = append(buf, v.validateDispatcher(path, &cfg.Spec.Dispatch[i])...)
+	}
+
+	return buf
+}
+
+// validateDispatcher checks fields required by dispatcher type, which are checked in dispatch.Init otherwise
+func (v *validate) validateDispatcher(path string, d *config.Dispatch) []issue {
+	var buf []issue
+
+	switch strings.ToLower(d.Type) {
+	case dispatchExec:
+		if len(d.Exec.Command) == 0 || d.Exec.Command[0] == "" {
+			buf = append(buf, issue{path + ".exec.command", "required"})
+		}
+	case dispatchJenkins:
+		if d.Jenkins.Url == "" {
+			buf = append(buf, issue{path + ".jenkins.url", "required"})
+		}
+		if d.Jenkins.Job == "" {
+			buf = append(buf, issue{path + ".jenkins.job", "required"})
+		}
+	case dispatchLog:
+	case dispatchWebhook:
+		if len(d.Webhook.Urls) == 0 {
+			buf = append(buf, issue{path + ".webhook.urls", "required"})
+		}
+		for i, item := range d.Webhook.Urls {
+			if item == "" {
+				buf = append(buf, issue{path + ".webhook.urls[" + strconv.Itoa(i) + "]", "required"})
+			}
+		}
+	case "":
+		buf = append(buf, issue{path + ".type", "required"})
+	default:
+		buf = append(buf, issue{path + ".type", "unknown type " + strconv.Quote(d.Type)})
+	}
+
+	return buf
+}
+
+func (v *validate) validateQueue(_ context.Context, cfg *config.Config) []issue {
+	var buf []issue
+
+	switch strings.ToLower(cfg.Spec.Queue.Type) {
+	case "", queueMemory:
+	case queueDisk:
+		if cfg.Spec.Queue.Path == "" {
+			buf = append(buf, issue{"spec.queue.path", "required"})
+		}
+	default:
+		buf = append(buf, issue{"spec.queue.type", "invalid type " + strconv.Quote(cfg.Spec.Queue.Type)})
+	}
+
+	if cfg.Spec.Queue.SegmentBytes < 0 {
+		buf = append(buf, issue{"spec.queue.segmentBytes", "invalid value"})
+	}
+
+	return buf
+}
+
+func (v *validate) validateRetry(_ context.Context, cfg *config.Config) []issue {
+	var buf []issue
+
+	if cfg.Spec.Retry.BackoffSeconds < 0 {
+		buf = append(buf, issue{"spec.retry.backoffSeconds", "invalid value"})
+	}
+
+	if cfg.Spec.Retry.Count < 0 {
+		buf = append(buf, issue{"spec.retry.count", "invalid value"})
+	}
+
+	return buf
+}
+
+func (v *validate) validateReview(_ context.Context, cfg *config.Config) []issue {
+	var buf []issue
+
+	votes := map[string]string{
+		"aborted":   cfg.Spec.Review.Aborted.Message,
+		"failed":    cfg.Spec.Review.Failed.Message,
+		"started":   cfg.Spec.Review.Started.Message,
+		"succeeded": cfg.Spec.Review.Succeeded.Message,
+		"unstable":  cfg.Spec.Review.Unstable.Message,
+	}
+
+	for key, val := range votes {
+		if err := v.validateTemplate(val); err != nil {
+			buf = append(buf, issue{"spec.review." + key + ".message", err.Error()})
+		}
+	}
+
+	for i := range cfg.Spec.Trigger.Jobs {
+		path := "spec.trigger.jobs[" + strconv.Itoa(i) + "].review"
+		job := &cfg.Spec.Trigger.Jobs[i].Review
+		jobs := map[string]string{
+			"aborted":   job.Aborted,
+			"failed":    job.Failed,
+			"started":   job.Started,
+			"succeeded": job.Succeeded,
+			"unstable":  job.Unstable,
+		}
+		for key, val := range jobs {
+			if err := v.validateTemplate(val); err != nil {
+				buf = append(buf, issue{path + "." + key, err.Error()})
+			}
+		}
+	}
+
+	slices.SortFunc(buf, func(a, b issue) int {
+		return strings.Compare(a.path, b.path)
+	})
+
+	return buf
+}
+
+func (v *validate) validateTrigger(ctx context.Context, cfg *config.Config) []issue {
+	var buf []issue
+
+	buf = append(buf, v.validateEvents(ctx, "spec.trigger.events", cfg.Spec.Trigger.Events)...)
+	buf = append(buf, v.validateProjects(ctx, "spec.trigger.projects", cfg.Spec.Trigger.Projects)...)
+
+	connects := map[string]bool{cfg.Spec.Connect.Name: true}
+	for i := range cfg.Spec.Connects {
+		connects[cfg.Spec.Connects[i].Name] = true
+	}
+
+	dispatch := map[string]bool{}
+	for i := range cfg.Spec.Dispatch {
+		dispatch[cfg.Spec.Dispatch[i].Name] = true
+	}
+
+	names := map[string]bool{}
+
+	for i := range cfg.Spec.Trigger.Jobs {
+		path := "spec.trigger.jobs[" + strconv.Itoa(i) + "]"
+		job := &cfg.Spec.Trigger.Jobs[i]
+		if job.Name == "" {
+			buf = append(buf, issue{path + ".name", "required"})
+		} else if names[job.Name] {
+			buf = append(buf, issue{path + ".name", "duplicate name " + strconv.Quote(job.Name)})
+		}
+		names[job.Name] = true
+		for j := range job.Connects {
+			if !connects[job.Connects[j]] {
+				buf = append(buf, issue{path + ".connects[" + strconv.Itoa(j) + "]", "unknown connect " + strconv.Quote(job.Connects[j])})
+			}
+		}
+		if job.Dispatch != "" && !dispatch[job.Dispatch] {
+			buf = append(buf, issue{path + ".dispatch", "unknown dispatch " + strconv.Quote(job.Dispatch)})
+		}
+		if len(job.Events) == 0 {
+			buf = append(buf, issue{path + ".events", "required"})
+		}
+		if len(job.Projects) == 0 {
+			buf = append(buf, issue{path + ".projects", "required"})
+		}
+		if job.QuietSeconds < 0 {
+			buf = append(buf, issue{path + ".quietSeconds", "invalid value"})
+		}
+		buf = append(buf, v.validateEvents(ctx, path+".events", job.Events)...)
+		buf = append(buf, v.validateProjects(ctx, path+".projects", job.Projects)...)
+	}
+
+	return buf
+}
+
+func (v *validate) validateWorker(_ context.Context, cfg *config.Config) []issue {
+	var buf []issue
+
+	if cfg.Spec.Worker.Count < 0 {
+		buf = append(buf, issue{"spec.worker.count", "invalid value"})
+	}
+
+	if cfg.Spec.Worker.QueueSize < 0 {
+		buf = append(buf, issue{"spec.worker.queueSize", "invalid value"})
+	}
+
+	switch strings.ToLower(cfg.Spec.Worker.Shard) {
+	case "", shardChange, shardProject:
+	default:
+		buf = append(buf, issue{"spec.worker.shard", "invalid shard " + strconv.Quote(cfg.Spec.Worker.Shard)})
+	}
+
+	return buf
+}
+
+func (v *validate) validateEvents(_ context.Context, path string, cfg []config.Event) []issue {
+	var buf []issue
+
+	for i := range cfg {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		// e.g., "Patchset Created" replaced with "patchset-created"
+		name := strings.Replace(strings.ToLower(cfg[i].Name), " ", eventSep, -1)
+		if name == "" {
+			buf = append(buf, issue{p + ".name", "required"})
+		} else if !slices.Contains(eventNames, name) {
+			buf = append(buf, issue{p + ".name", "unknown event " + strconv.Quote(cfg[i].Name)})
+		}
+		if err := v.validateRegExp(cfg[i].CommentAddedContainsRegularExpression.Value); err != nil {
+			buf = append(buf, issue{p + ".commentAddedContainsRegularExpression.value", err.Error()})
+		}
+		if err := v.validateRegExp(cfg[i].CommitMessage); err != nil {
+			buf = append(buf, issue{p + ".commitMessage", err.Error()})
+		}
+		if err := v.validateRegExp(cfg[i].UploaderName); err != nil {
+			buf = append(buf, issue{p + ".uploaderName", err.Error()})
+		}
+	}
+
+	return buf
+}
+
+func (v *validate) validateProjects(_ context.Context, path string, cfg []config.Project) []issue {
+	helper := func(p string, match []config.Match) []issue {
+		var buf []issue
+		for i := range match {
+			buf = append(buf, v.validateMatch(p+"["+strconv.Itoa(i)+"]", &match[i])...)
+		}
+		return buf
+	}
+
+	var buf []issue
+
+	for i := range cfg {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		if len(cfg[i].Branches) == 0 {
+			buf = append(buf, issue{p + ".branches", "required"})
+		}
+		buf = append(buf, helper(p+".branches", cfg[i].Branches)...)
+		buf = append(buf, helper(p+".filePaths", cfg[i].FilePaths)...)
+		buf = append(buf, helper(p+".forbiddenFilePaths", cfg[i].ForbiddenFilePaths)...)
+		buf = append(buf, v.validateMatch(p+".repo", &cfg[i].Repo)...)
+		buf = append(buf, helper(p+".topics", cfg[i].Topics)...)
+	}
+
+	return buf
+}
+
+func (v *validate) validateMatch(path string, match *config.Match) []issue {
+	var buf []issue
+
+	if match.Pattern == "" {
+		buf = append(buf, issue{path + ".pattern", "required"})
+	}
+
+	switch strings.ToLower(match.Type) {
+	case matchPath:
+		if err := v.validatePath(match.Pattern); err != nil {
+			buf = append(buf, issue{path + ".pattern", err.Error()})
+		}
+	case matchPlain:
+	case matchRegExp:
+		if err := v.validateRegExp(match.Pattern); err != nil {
+			buf = append(buf, issue{path + ".pattern", err.Error()})
+		}
+	case "":
+		buf = append(buf, issue{path + ".type", "required"})
+	default:
+		buf = append(buf, issue{path + ".type", "unknown type " + strconv.Quote(match.Type)})
+	}
+
+	return buf
+}
+
+func (v *validate) validateRegExp(pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return errors.Wrap(err, "invalid regexp")
+	}
+
+	return nil
+}
+
+// validatePath compiles ant-style path pattern, which panics in antpath if invalid
+func (v *validate) validatePath(pattern string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("invalid path pattern")
+		}
+	}()
+
+	_ = antpath.New().Match(pattern, pattern)
+
+	return nil
+}
+
+// validateTemplate parses review message template, and the parse error tells what is wrong
+func (v *validate) validateTemplate(text string) error {
+	if _, err := template.New("").Parse(text); err != nil {
+		return errors.Wrap(err, "invalid template")
+	}
+
+	return nil
+}
+
+// line returns line number of YAML path, or of the nearest parent if path is not set
+func (v *validate) line(root *yaml.Node, path string) int {
+	node := root
+
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		node = node.Content[0]
+	}
+
+	for _, item := range strings.Split(path, ".") {
+		name, index := item, -1
+		if i := strings.Index(item, "["); i >= 0 && strings.HasSuffix(item, "]") {
+			name = item[:i]
+			index, _ = strconv.Atoi(item[i+1 : len(item)-1])
+		}
+		next := v.child(node, name)
+		if next == nil {
+			return node.Line
+		}
+		node = next
+		if index < 0 {
+			continue
+		}
+		if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+			return node.Line
+		}
+		node = node.Content[index]
+	}
+
+	return node.Line
+}
+
+func (v *validate) child(node *yaml.Node, name string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package validate
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	configInvalid = `apiVersion: v1
+kind: trigger
+spec:
+  connect:
+    hostname: localhost
+    ssh:
+      keyfile: /path/to/.ssh/id_rsa
+      username: user
+  trigger:
+    jobs:
+      - name: build
+        dispatch: invalid
+        events:
+          - name: "patchset-created"
+            commitMessage: "message(["
+          - name: "invalid-event"
+        projects:
+          - branches:
+              - pattern: main
+                type: invalid
+            filePaths:
+              - pattern: "a/{b:[}"
+                type: path
+            repo:
+              pattern: "[a-"
+              type: regexp
+        review:
+          failed: "{{ invalid"
+`
+	configUnknown = `apiVersion: v1
+kind: trigger
+spec:
+  connect:
+    hostname: localhost
+    unknown: value
+`
+)
+
+func initValidate() validate {
+	v := validate{
+		cfg: DefaultConfig(),
+	}
+
+	v.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "validate",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	return v
+}
+
+func TestRun(t *testing.T) {
+	v := initValidate()
+	ctx := context.Background()
+
+	buf, _ := os.ReadFile("../test/config/config.yml")
+
+	c, err := v.Run(ctx, buf)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "localhost", c.Spec.Connect.Hostname)
+
+	_, err = v.Run(ctx, []byte(configUnknown))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 6: field unknown not found")
+
+	_, err = v.Run(ctx, []byte(configInvalid))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 7: spec.connect.ssh.port: required")
+	assert.Contains(t, err.Error(), "line 12: spec.trigger.jobs[0].dispatch: unknown dispatch \"invalid\"")
+	assert.Contains(t, err.Error(), "line 15: spec.trigger.jobs[0].events[0].commitMessage: invalid regexp")
+	assert.Contains(t, err.Error(), "line 16: spec.trigger.jobs[0].events[1].name: unknown event \"invalid-event\"")
+	assert.Contains(t, err.Error(), "line 20: spec.trigger.jobs[0].projects[0].branches[0].type: unknown type \"invalid\"")
+	assert.Contains(t, err.Error(), "line 22: spec.trigger.jobs[0].projects[0].filePaths[0].pattern: invalid path pattern")
+	assert.Contains(t, err.Error(), "line 25: spec.trigger.jobs[0].projects[0].repo.pattern: invalid regexp")
+	assert.Contains(t, err.Error(), "line 28: spec.trigger.jobs[0].review.failed: invalid template: template: :1: function \"invalid\" not defined")
+}
+
+func TestLine(t *testing.T) {
+	v := initValidate()
+
+	_, err := v.Run(context.Background(), []byte("spec:\n  trigger:\n    jobs:\n      - name: \"\"\n"))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 4: spec.trigger.jobs[0].name: required")
+	assert.Contains(t, err.Error(), "line 4: spec.trigger.jobs[0].events: required")
+	assert.Contains(t, err.Error(), "line 2: spec.connect.hostname: required")
+
+	_, err = v.Run(context.Background(), []byte("spec:\n  trigger:\n    jobs:\n      - name: build\n        quietSeconds: -1\n"))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 5: spec.trigger.jobs[0].quietSeconds: invalid value")
+}
+
+func TestQueue(t *testing.T) {
+	v := initValidate()
+
+	_, err := v.Run(context.Background(), []byte("spec:\n  queue:\n    type: disk\n"))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 3: spec.queue.path: required")
+
+	_, err = v.Run(context.Background(), []byte("spec:\n  queue:\n    type: invalid\n"))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 3: spec.queue.type: invalid type \"invalid\"")
+}
+
+func TestWorker(t *testing.T) {
+	v := initValidate()
+
+	_, err := v.Run(context.Background(), []byte("spec:\n  worker:\n    count: -1\n    shard: invalid\n"))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 3: spec.worker.count: invalid value")
+	assert.Contains(t, err.Error(), "line 4: spec.worker.shard: invalid shard \"invalid\"")
+}
+
+func TestRetry(t *testing.T) {
+	v := initValidate()
+
+	_, err := v.Run(context.Background(), []byte("spec:\n  retry:\n    backoffSeconds: -1\n    count: -1\n"))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 3: spec.retry.backoffSeconds: invalid value")
+	assert.Contains(t, err.Error(), "line 4: spec.retry.count: invalid value")
+}
+
+func TestFingerprints(t *testing.T) {
+	v := initValidate()
+
+	_, err := v.Run(context.Background(), []byte("spec:\n  connect:\n    ssh:\n      fingerprints:\n        - SHA256:abc\n        - invalid\n"))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 6: spec.connect.ssh.fingerprints[1]: invalid fingerprint \"invalid\"")
+	assert.NotContains(t, err.Error(), "fingerprints[0]")
+}
+
+func TestAuthMethods(t *testing.T) {
+	v := initValidate()
+
+	_, err := v.Run(context.Background(), []byte("spec:\n  connect:\n    ssh:\n      authMethods:\n        - keyfile\n        - invalid\n"))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 6: spec.connect.ssh.authMethods[1]: invalid method \"invalid\"")
+	assert.Contains(t, err.Error(), "spec.connect.ssh.keyfile: required")
+
+	_, err = v.Run(context.Background(), []byte("spec:\n  connect:\n    ssh:\n      authMethods:\n        - agent\n"))
+	assert.NotEqual(t, nil, err)
+	assert.NotContains(t, err.Error(), "spec.connect.ssh.keyfile")
+}
+
+func TestDispatch(t *testing.T) {
+	v := initValidate()
+
+	_, err := v.Run(context.Background(), []byte(`spec:
+  dispatch:
+    - name: exec
+      type: exec
+    - name: jenkins
+      type: jenkins
+      jenkins:
+        url: http://localhost:8083
+    - name: webhook
+      type: webhook
+    - name: invalid
+      type: invalid
+    - name: log
+      type: log
+`))
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "line 3: spec.dispatch[0].exec.command: required")
+	assert.Contains(t, err.Error(), "line 8: spec.dispatch[1].jenkins.job: required")
+	assert.NotContains(t, err.Error(), "spec.dispatch[1].jenkins.url")
+	assert.Contains(t, err.Error(), "line 9: spec.dispatch[2].webhook.urls: required")
+	assert.Contains(t, err.Error(), "line 12: spec.dispatch[3].type: unknown type \"invalid\"")
+	assert.NotContains(t, err.Error(), "spec.dispatch[4]")
+}
@@ -3,6 +3,7 @@ package watchdog
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
@@ -13,31 +14,47 @@ import (
 )
 
 const (
-	prefix = "gerrit version"
+	// Connection is reconnected after consecutive failures, to keep healthy stream on one slow check
+	failures = 3
+	prefix   = "gerrit version"
 )
 
 type Watchdog interface {
 	Init(context.Context) error
 	Deinit(context.Context) error
-	Start(context.Context, chan bool, chan bool) error
+	Start(context.Context) error
 	Stop(context.Context) error
+	Health(context.Context) Health
 }
 
+// Config to check the connection of Ssh shared with trigger, which is initialized by trigger
 type Config struct {
 	Config config.Config
 	Logger hclog.Logger
 	Ssh    connect.Ssh
 }
 
+// Health to store the result of checks, and failures are counted since the last success
+type Health struct {
+	Checked    time.Time `json:"checked"`
+	Error      string    `json:"error,omitempty"`
+	Failures   int       `json:"failures"`
+	Healthy    bool      `json:"healthy"`
+	Reconnects int       `json:"reconnects"`
+}
+
 type watchdog struct {
-	cfg  *Config
-	done chan bool
+	cfg    *Config
+	cancel context.CancelFunc
+	done   chan struct{}
+	health Health
+	mutex  sync.RWMutex
 }
 
 func New(_ context.Context, cfg *Config) Watchdog {
 	return &watchdog{
-		cfg:  cfg,
-		done: make(chan bool, 1),
+		cfg:    cfg,
+		health: Health{Healthy: true},
 	}
 }
 
@@ -45,46 +62,45 @@ func DefaultConfig() *Config {
 	return &Config{}
 }
 
-func (w *watchdog) Init(ctx context.Context) error {
+func (w *watchdog) Init(_ context.Context) error {
 	w.cfg.Logger.Debug("watchdog: Init")
 
-	if err := w.cfg.Ssh.Init(ctx); err != nil {
-		return errors.Wrap(err, "failed to init ssh")
-	}
-
 	return nil
 }
 
-func (w *watchdog) Deinit(ctx context.Context) error {
+func (w *watchdog) Deinit(_ context.Context) error {
 	w.cfg.Logger.Debug("watchdog: Deinit")
 
-	_ = w.cfg.Ssh.Deinit(ctx)
-
 	return nil
 }
 
-func (w *watchdog) Start(ctx context.Context, conn, done chan bool) error {
-	p := time.Duration(w.cfg.Config.Spec.Watchdog.PeriodSeconds) * time.Second
+// Start to check connection periodically in background until stopped
+func (w *watchdog) Start(ctx context.Context) error {
+	w.cfg.Logger.Debug("watchdog: Start")
 
-	if p == 0 {
-		done <- true
+	p := time.Duration(w.cfg.Config.Spec.Watchdog.PeriodSeconds) * time.Second
+	if p <= 0 {
 		return nil
 	}
 
-	ticker := time.NewTicker(p * time.Second)
-	defer ticker.Stop()
+	if w.cancel != nil {
+		return errors.New("watchdog started")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	w.cancel = cancel
+	w.done = make(chan struct{})
 
 	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(p)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				if err := w.check(ctx); err == nil {
-					conn <- true
-				} else {
-					conn <- false
-				}
-			case <-w.done:
-				done <- true
+				w.probe(ctx)
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -94,10 +110,76 @@ func (w *watchdog) Start(ctx context.Context, conn, done chan bool) error {
 }
 
 func (w *watchdog) Stop(_ context.Context) error {
-	w.done <- true
+	w.cfg.Logger.Debug("watchdog: Stop")
+
+	if w.cancel == nil {
+		return nil
+	}
+
+	w.cancel()
+	<-w.done
+
+	w.cancel = nil
+
 	return nil
 }
 
+func (w *watchdog) Health(_ context.Context) Health {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	return w.health
+}
+
+// probe to check connection, and reconnect on consecutive failures to recover stream events
+func (w *watchdog) probe(ctx context.Context) {
+	c := ctx
+
+	if t := time.Duration(w.cfg.Config.Spec.Watchdog.TimeoutSeconds) * time.Second; t > 0 {
+		var cancel context.CancelFunc
+		c, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+
+	err := w.check(c)
+	if ctx.Err() != nil {
+		return
+	}
+
+	w.mutex.Lock()
+	w.health.Checked = time.Now()
+	if err == nil {
+		w.health.Error = ""
+		w.health.Failures = 0
+		w.health.Healthy = true
+	} else {
+		w.health.Error = err.Error()
+		w.health.Failures++
+		w.health.Healthy = false
+	}
+	count := w.health.Failures
+	w.mutex.Unlock()
+
+	if err == nil {
+		return
+	}
+
+	w.cfg.Logger.Warn("watchdog: probe", "failures", count, "error", err.Error())
+
+	if count%failures != 0 {
+		return
+	}
+
+	if err := w.cfg.Ssh.Reconnect(ctx); err != nil {
+		w.cfg.Logger.Error("watchdog: probe", "error", err.Error())
+		return
+	}
+
+	w.mutex.Lock()
+	w.health.Reconnects++
+	w.mutex.Unlock()
+}
+
 func (w *watchdog) check(ctx context.Context) error {
 	b, err := w.cfg.Ssh.Run(ctx, "version")
 	if err != nil {
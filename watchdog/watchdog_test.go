@@ -2,21 +2,50 @@ package watchdog
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/gerrittrigger/trigger/config"
 	"github.com/gerrittrigger/trigger/connect"
 )
 
-func initWatchdog() watchdog {
-	w := watchdog{
-		cfg:  DefaultConfig(),
-		done: make(chan bool, 1),
+// testSsh to fake version check failed until reconnected
+type testSsh struct {
+	connect.Ssh
+	connected bool
+	mutex     sync.Mutex
+	reconnect int
+}
+
+func (s *testSsh) Run(_ context.Context, _ string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.connected {
+		return "", errors.New("invalid client")
 	}
 
+	return prefix + " 3.9.1\n", nil
+}
+
+func (s *testSsh) Reconnect(_ context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.connected = true
+	s.reconnect++
+
+	return nil
+}
+
+func initWatchdog() *watchdog {
+	w := New(context.Background(), DefaultConfig()).(*watchdog)
+
 	w.cfg.Config = config.Config{}
 
 	w.cfg.Logger = hclog.New(&hclog.LoggerOptions{
@@ -24,8 +53,7 @@ func initWatchdog() watchdog {
 		Level: hclog.LevelFromString("INFO"),
 	})
 
-	_ssh := connect.DefaultSshConfig()
-	w.cfg.Ssh = connect.SshNew(context.Background(), _ssh)
+	w.cfg.Ssh = &testSsh{}
 
 	return w
 }
@@ -34,21 +62,58 @@ func TestRun(t *testing.T) {
 	w := initWatchdog()
 	ctx := context.Background()
 
-	conn := make(chan bool, 1)
-	done := make(chan bool, 1)
-
 	w.cfg.Config.Spec.Watchdog.PeriodSeconds = 0
 
-	err := w.Start(ctx, conn, done)
+	err := w.Start(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, w.Health(ctx).Healthy)
+
+	err = w.Stop(ctx)
 	assert.Equal(t, nil, err)
-	assert.Equal(t, true, <-done)
 
-	w.cfg.Config.Spec.Watchdog.PeriodSeconds = 2
+	w.cfg.Config.Spec.Watchdog.PeriodSeconds = 1
 
-	err = w.Start(ctx, conn, done)
+	err = w.Start(ctx)
 	assert.Equal(t, nil, err)
 
+	err = w.Start(ctx)
+	assert.NotEqual(t, nil, err)
+
 	err = w.Stop(ctx)
 	assert.Equal(t, nil, err)
-	assert.Equal(t, true, <-done)
+}
+
+func TestProbe(t *testing.T) {
+	w := initWatchdog()
+	ctx := context.Background()
+
+	w.cfg.Config.Spec.Watchdog.TimeoutSeconds = 1
+
+	w.probe(ctx)
+
+	// Not reconnected on one failure
+	h := w.Health(ctx)
+	assert.Equal(t, false, h.Healthy)
+	assert.Equal(t, 1, h.Failures)
+	assert.Equal(t, 0, h.Reconnects)
+
+	for i := 1; i < failures; i++ {
+		w.probe(ctx)
+	}
+
+	h = w.Health(ctx)
+	assert.Equal(t, false, h.Healthy)
+	assert.Equal(t, failures, h.Failures)
+	assert.Equal(t, 1, h.Reconnects)
+	assert.NotEqual(t, "", h.Error)
+
+	// Recovered after reconnection
+	w.probe(ctx)
+
+	h = w.Health(ctx)
+	assert.Equal(t, true, h.Healthy)
+	assert.Equal(t, 0, h.Failures)
+	assert.Equal(t, 1, h.Reconnects)
+	assert.Equal(t, "", h.Error)
+	assert.Less(t, time.Since(h.Checked), time.Second)
 }
@@ -0,0 +1,253 @@
+package worker
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+const (
+	count     = 1
+	queueSize = 16
+)
+
+var (
+	ErrClosed = errors.New("worker closed")
+)
+
+type Worker interface {
+	Init(context.Context) error
+	Deinit(context.Context) error
+	Run(context.Context, string, Task) error
+	Wait(context.Context) error
+	Stats(context.Context) Stats
+}
+
+type Config struct {
+	Config config.Config
+	Logger hclog.Logger
+}
+
+// Task to process one event, tasks of the same key run in order on one worker
+type Task func() error
+
+// Stats to store utilization of workers
+type Stats struct {
+	Count   int    `json:"count"`
+	Workers []Stat `json:"workers"`
+}
+
+// Stat to store utilization of one worker, utilization is the ratio of busy time since init
+type Stat struct {
+	Busy        bool    `json:"busy"`
+	Processed   uint64  `json:"processed"`
+	Queued      int     `json:"queued"`
+	Utilization float64 `json:"utilization"`
+}
+
+type shard struct {
+	busy      time.Duration
+	processed uint64
+	since     time.Time
+	tasks     chan Task
+}
+
+type worker struct {
+	cfg     *Config
+	cancel  context.CancelFunc
+	closed  bool
+	ctx     context.Context
+	err     error
+	group   sync.WaitGroup
+	lock    sync.RWMutex
+	mutex   sync.Mutex
+	shards  []*shard
+	started time.Time
+}
+
+func New(_ context.Context, cfg *Config) Worker {
+	return &worker{
+		cfg: cfg,
+	}
+}
+
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+func (w *worker) Init(ctx context.Context) error {
+	w.cfg.Logger.Debug("worker: Init")
+
+	n := w.cfg.Config.Spec.Worker.Count
+	if n <= 0 {
+		n = count
+	}
+
+	size := w.cfg.Config.Spec.Worker.QueueSize
+	if size <= 0 {
+		size = queueSize
+	}
+
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	w.shards = make([]*shard, n)
+	w.started = time.Now()
+
+	for i := range w.shards {
+		w.shards[i] = &shard{
+			tasks: make(chan Task, size),
+		}
+		w.group.Add(1)
+		go w.work(w.shards[i])
+	}
+
+	return nil
+}
+
+func (w *worker) Deinit(ctx context.Context) error {
+	w.cfg.Logger.Debug("worker: Deinit")
+
+	if w.cancel == nil {
+		return nil
+	}
+
+	_ = w.Wait(ctx)
+	w.cancel()
+
+	s := w.Stats(ctx)
+	for i := range s.Workers {
+		w.cfg.Logger.Debug("worker: Deinit", "worker", i, "processed", s.Workers[i].Processed,
+			"utilization", s.Workers[i].Utilization)
+	}
+
+	return nil
+}
+
+// Run to queue task on the worker sharded by key, and block if the worker is full
+func (w *worker) Run(ctx context.Context, key string, task Task) error {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	s := w.shards[w.index(key)]
+
+	select {
+	case s.tasks <- task:
+		return nil
+	case <-w.ctx.Done():
+		return w.error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait to stop accepting tasks and wait for queued tasks to finish, the first error of tasks is returned
+func (w *worker) Wait(_ context.Context) error {
+	w.lock.Lock()
+
+	if !w.closed {
+		w.closed = true
+		for _, item := range w.shards {
+			close(item.tasks)
+		}
+	}
+
+	w.lock.Unlock()
+
+	w.group.Wait()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.err
+}
+
+func (w *worker) Stats(_ context.Context) Stats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(w.started)
+
+	buf := Stats{
+		Count:   len(w.shards),
+		Workers: make([]Stat, len(w.shards)),
+	}
+
+	for i, item := range w.shards {
+		busy := item.busy
+		if !item.since.IsZero() {
+			busy += now.Sub(item.since)
+		}
+		buf.Workers[i] = Stat{
+			Busy:      !item.since.IsZero(),
+			Processed: item.processed,
+			Queued:    len(item.tasks),
+		}
+		if elapsed > 0 {
+			buf.Workers[i].Utilization = float64(busy) / float64(elapsed)
+		}
+	}
+
+	return buf
+}
+
+func (w *worker) work(s *shard) {
+	defer w.group.Done()
+
+	for task := range s.tasks {
+		// Drop queued tasks after failure
+		if w.ctx.Err() != nil {
+			continue
+		}
+		w.mutex.Lock()
+		s.since = time.Now()
+		w.mutex.Unlock()
+		err := task()
+		w.mutex.Lock()
+		s.busy += time.Since(s.since)
+		s.processed++
+		s.since = time.Time{}
+		w.mutex.Unlock()
+		if err != nil {
+			w.fail(err)
+		}
+	}
+}
+
+func (w *worker) fail(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err == nil {
+		w.err = err
+	}
+
+	w.cancel()
+}
+
+func (w *worker) error() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	return ErrClosed
+}
+
+func (w *worker) index(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(len(w.shards)))
+}
@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gerrittrigger/trigger/config"
+)
+
+func initWorker(n int) *worker {
+	w := &worker{
+		cfg: DefaultConfig(),
+	}
+
+	w.cfg.Config = config.Config{}
+	w.cfg.Config.Spec.Worker.Count = n
+
+	w.cfg.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:  "worker",
+		Level: hclog.LevelFromString("INFO"),
+	})
+
+	return w
+}
+
+func TestRun(t *testing.T) {
+	w := initWorker(4)
+	ctx := context.Background()
+
+	err := w.Init(ctx)
+	assert.Equal(t, nil, err)
+
+	var mutex sync.Mutex
+
+	buf := map[string][]int{}
+
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i % 5)
+		val := i
+		err = w.Run(ctx, key, func() error {
+			mutex.Lock()
+			buf[key] = append(buf[key], val)
+			mutex.Unlock()
+			return nil
+		})
+		assert.Equal(t, nil, err)
+	}
+
+	err = w.Wait(ctx)
+	assert.Equal(t, nil, err)
+
+	// Tasks of the same key run in order
+	for key, val := range buf {
+		assert.Equal(t, 20, len(val))
+		for i := 1; i < len(val); i++ {
+			assert.Less(t, val[i-1], val[i], key)
+		}
+	}
+
+	err = w.Run(ctx, "0", func() error { return nil })
+	assert.Equal(t, ErrClosed, err)
+
+	s := w.Stats(ctx)
+	assert.Equal(t, 4, s.Count)
+
+	total := uint64(0)
+	for _, item := range s.Workers {
+		total += item.Processed
+	}
+
+	assert.Equal(t, uint64(100), total)
+
+	_ = w.Deinit(ctx)
+}
+
+func TestError(t *testing.T) {
+	w := initWorker(1)
+	ctx := context.Background()
+
+	_ = w.Init(ctx)
+
+	_ = w.Run(ctx, "key", func() error {
+		return errors.New("invalid")
+	})
+
+	err := w.Wait(ctx)
+	assert.NotEqual(t, nil, err)
+
+	_ = w.Deinit(ctx)
+}
+
+func TestIndex(t *testing.T) {
+	w := initWorker(3)
+
+	_ = w.Init(context.Background())
+
+	assert.Equal(t, w.index("project/1"), w.index("project/1"))
+	assert.Less(t, w.index("project/2"), 3)
+
+	_ = w.Deinit(context.Background())
+}